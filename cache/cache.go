@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Cache fetches the value of each key once, handing it to every caller
+// asking for the key, at the same time or later. With a directory, values
+// are also kept on disk and reused by later runs until older than the TTL.
+type Cache[V any] struct {
+	mu      sync.Mutex
+	entries map[string]*entry[V]
+
+	dir string
+	ttl time.Duration
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+type entry[V any] struct {
+	ready chan struct{}
+	value V
+}
+
+// stored is a value as kept on disk.
+type stored[V any] struct {
+	Key      string    `json:"key"`
+	StoredAt time.Time `json:"storedAt"`
+	Value    V         `json:"value"`
+}
+
+// New returns a cache kept in memory, and in dir when it is not empty.
+func New[V any](dir string, ttl time.Duration) (*Cache[V], error) {
+	if dir != "" {
+		if ttl <= 0 {
+			return nil, fmt.Errorf("cache: ttl must be positive, got %s", ttl)
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("cache: could not create directory: %w", err)
+		}
+	}
+	return &Cache[V]{entries: map[string]*entry[V]{}, dir: dir, ttl: ttl}, nil
+}
+
+// Get returns the value of key, calling fetch when no caller fetched it yet
+// and it is not on disk. Callers asking for a key being fetched wait for it.
+// The value is kept on disk when fetch returns true, which allows leaving out
+// failures worth trying again on the next run. The error tells the disk
+// cache could not be read or written; the value is good all the same.
+func (c *Cache[V]) Get(key string, fetch func() (V, bool)) (V, error) {
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok {
+		c.mu.Unlock()
+		<-e.ready
+		c.hits.Add(1)
+		return e.value, nil
+	}
+	e := &entry[V]{ready: make(chan struct{})}
+	c.entries[key] = e
+	c.mu.Unlock()
+	defer close(e.ready)
+
+	value, ok, err := c.load(key)
+	if ok {
+		c.hits.Add(1)
+		e.value = value
+		return value, nil
+	}
+
+	c.misses.Add(1)
+	value, keep := fetch()
+	e.value = value
+	if keep {
+		if storeErr := c.store(key, value); storeErr != nil {
+			err = storeErr
+		}
+	}
+	return value, err
+}
+
+// Stats returns how many values were reused and how many were fetched.
+func (c *Cache[V]) Stats() (hits int64, misses int64) {
+	return c.hits.Load(), c.misses.Load()
+}
+
+func (c *Cache[V]) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// load reads key from disk. Missing, expired and unreadable values are not
+// found, so they are fetched again and overwritten.
+func (c *Cache[V]) load(key string) (V, bool, error) {
+	var s stored[V]
+	if c.dir == "" {
+		return s.Value, false, nil
+	}
+	data, err := os.ReadFile(c.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return s.Value, false, nil
+	}
+	if err != nil {
+		return s.Value, false, fmt.Errorf("cache: could not read %q: %w", key, err)
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s.Value, false, fmt.Errorf("cache: could not decode %q: %w", key, err)
+	}
+	if s.Key != key || time.Since(s.StoredAt) > c.ttl {
+		return s.Value, false, nil
+	}
+	return s.Value, true, nil
+}
+
+// store writes value through a temporary file, so runs sharing the cache
+// never read a value cut in half.
+func (c *Cache[V]) store(key string, value V) error {
+	if c.dir == "" {
+		return nil
+	}
+	data, err := json.Marshal(stored[V]{Key: key, StoredAt: time.Now().UTC(), Value: value})
+	if err != nil {
+		return fmt.Errorf("cache: could not encode %q: %w", key, err)
+	}
+	file, err := os.CreateTemp(c.dir, "*.tmp")
+	if err != nil {
+		return fmt.Errorf("cache: could not write %q: %w", key, err)
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), c.path(key))
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return fmt.Errorf("cache: could not write %q: %w", key, err)
+	}
+	return nil
+}
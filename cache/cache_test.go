@@ -0,0 +1,104 @@
+package cache_test
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/cache"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetFetchesOnce(t *testing.T) {
+	c, err := cache.New[string]("", 0)
+	require.NoError(t, err)
+
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	fetch := func() (string, bool) {
+		fetches.Add(1)
+		<-release
+		return "page", true
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := c.Get("https://www.cliquefarma.com.br/sem-categoria", fetch)
+			require.NoError(t, err)
+			require.Equal(t, "page", value)
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	require.Equal(t, int32(1), fetches.Load())
+	hits, misses := c.Stats()
+	require.Equal(t, int64(9), hits)
+	require.Equal(t, int64(1), misses)
+}
+
+func TestGetFromDisk(t *testing.T) {
+	type probe struct {
+		Status int
+	}
+
+	testCases := []struct {
+		desc string
+
+		ttl      time.Duration
+		keep     bool
+		wait     time.Duration
+		expected int
+	}{
+		{desc: "reused by the next run", ttl: time.Hour, keep: true, expected: 200},
+		{desc: "expired", ttl: time.Millisecond, keep: true, wait: 5 * time.Millisecond, expected: 404},
+		{desc: "not kept", ttl: time.Hour, keep: false, expected: 404},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "probes")
+			first, err := cache.New[probe](dir, tC.ttl)
+			require.NoError(t, err)
+			_, err = first.Get("https://www.cliquefarma.com.br/a", func() (probe, bool) { return probe{Status: 200}, tC.keep })
+			require.NoError(t, err)
+			time.Sleep(tC.wait)
+
+			second, err := cache.New[probe](dir, tC.ttl)
+			require.NoError(t, err)
+			value, err := second.Get("https://www.cliquefarma.com.br/a", func() (probe, bool) { return probe{Status: 404}, false })
+			require.NoError(t, err)
+			require.Equal(t, tC.expected, value.Status)
+		})
+	}
+}
+
+func TestGetIgnoresCorruptedFiles(t *testing.T) {
+	dir := t.TempDir()
+	c, err := cache.New[int](dir, time.Hour)
+	require.NoError(t, err)
+	_, err = c.Get("key", func() (int, bool) { return 1, true })
+	require.NoError(t, err)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.NoError(t, os.WriteFile(files[0], []byte("{\"key\":"), 0o600))
+
+	c, err = cache.New[int](dir, time.Hour)
+	require.NoError(t, err)
+	value, err := c.Get("key", func() (int, bool) { return 2, true })
+	require.Error(t, err)
+	require.Equal(t, 2, value)
+}
+
+func TestNewRequiresTTL(t *testing.T) {
+	_, err := cache.New[int](t.TempDir(), 0)
+	require.Error(t, err)
+}
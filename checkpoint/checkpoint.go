@@ -0,0 +1,158 @@
+package checkpoint
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Key identifies an analyzed De/Para pair of a product.
+type Key struct {
+	Sku  string
+	From string
+	To   string
+}
+
+// Store keeps the pairs already analyzed in a file, one CSV line per pair,
+// so an interrupted run can skip them when resumed.
+type Store struct {
+	mu     sync.Mutex
+	file   *os.File
+	writer *csv.Writer
+	done   map[Key]bool
+}
+
+// Open opens the state file at path. When resume is true the pairs already
+// in the file are loaded and new ones appended; otherwise the file is truncated.
+func Open(path string, resume bool) (*Store, error) {
+	done := map[Key]bool{}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if resume {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		if err := load(path, done); err != nil {
+			return nil, err
+		}
+	}
+
+	file, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("checkpoint: could not open state file: %w", err)
+	}
+	if resume {
+		if err := EndLine(path, file); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	return &Store{
+		file:   file,
+		writer: csv.NewWriter(file),
+		done:   done,
+	}, nil
+}
+
+func load(path string, done map[Key]bool) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("checkpoint: could not read state file: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(bufio.NewReader(file))
+	reader.FieldsPerRecord = 3
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			// A line cut by a crash is the last one; the pair is analyzed again.
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				continue
+			}
+			return fmt.Errorf("checkpoint: could not read state file: %w", err)
+		}
+		done[Key{Sku: record[0], From: record[1], To: record[2]}] = true
+	}
+}
+
+// EndLine terminates the last line of file, opened for appending, when it
+// was cut by a crash, so the next line written is not glued to it.
+func EndLine(path string, file *os.File) error {
+	info, err := file.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+
+	reader, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("checkpoint: could not read %s: %w", path, err)
+	}
+	defer reader.Close()
+
+	last := make([]byte, 1)
+	if _, err := reader.ReadAt(last, info.Size()-1); err != nil {
+		return fmt.Errorf("checkpoint: could not read %s: %w", path, err)
+	}
+	if last[0] == '\n' {
+		return nil
+	}
+	if _, err := file.Write([]byte("\n")); err != nil {
+		return fmt.Errorf("checkpoint: could not write %s: %w", path, err)
+	}
+	return nil
+}
+
+// Len returns the number of pairs already analyzed.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.done)
+}
+
+// Done reports whether the pair was already analyzed.
+func (s *Store) Done(key Key) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.done[key]
+}
+
+// Mark records the pair as analyzed, writing it to the file right away.
+func (s *Store) Mark(key Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done[key] {
+		return nil
+	}
+	s.done[key] = true
+
+	if err := s.writer.Write([]string{key.Sku, key.From, key.To}); err != nil {
+		return fmt.Errorf("checkpoint: could not write state file: %w", err)
+	}
+	s.writer.Flush()
+	return s.writer.Error()
+}
+
+// Close flushes and closes the state file.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.writer.Flush()
+	if err := s.writer.Error(); err != nil {
+		s.file.Close()
+		return err
+	}
+	return s.file.Close()
+}
@@ -0,0 +1,57 @@
+package checkpoint_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/checkpoint"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "output.csv.state")
+	first := checkpoint.Key{Sku: "1", From: "https://www.cliquefarma.com.br/a,b", To: "https://www.cliquefarma.com.br/a-b"}
+	second := checkpoint.Key{Sku: "2", From: "https://www.cliquefarma.com.br/c:g", To: "https://www.cliquefarma.com.br/c-g"}
+
+	store, err := checkpoint.Open(path, false)
+	require.NoError(t, err)
+	require.False(t, store.Done(first))
+	require.NoError(t, store.Mark(first))
+	require.True(t, store.Done(first))
+	require.NoError(t, store.Close())
+
+	testCases := []struct {
+		desc string
+
+		resume   bool
+		expected int
+	}{
+		{desc: "resuming loads the pairs already analyzed", resume: true, expected: 1},
+		{desc: "not resuming starts over", resume: false, expected: 0},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			store, err := checkpoint.Open(path, tC.resume)
+			require.NoError(t, err)
+			defer store.Close()
+
+			require.Equal(t, tC.expected, store.Len())
+			require.Equal(t, tC.resume, store.Done(first))
+		})
+	}
+
+	// Appending after a crash that cut the last line.
+	require.NoError(t, os.WriteFile(path, []byte("1,\"https://www.cliquefarma.com.br/a,b\",https://www.cliquefarma.com.br/a-b\n2,https://www.cliq"), 0o600))
+	store, err = checkpoint.Open(path, true)
+	require.NoError(t, err)
+	require.NoError(t, store.Mark(second))
+	require.NoError(t, store.Close())
+
+	store, err = checkpoint.Open(path, true)
+	require.NoError(t, err)
+	defer store.Close()
+	require.True(t, store.Done(first))
+	require.True(t, store.Done(second))
+	require.Equal(t, 2, store.Len())
+}
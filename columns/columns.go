@@ -0,0 +1,250 @@
+package columns
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// indexPlaceholder marks where the pair or subcategory number goes in an alias,
+// e.g. "Url{n}De" matches Url1De, Url2De, Url15De...
+const indexPlaceholder = "{n}"
+
+// Aliases lists, for each known column, the headers accepted for it.
+// Headers are compared ignoring case, spaces, '-' and '_'.
+type Aliases struct {
+	Sku          []string `yaml:"sku"`
+	OldSlug      []string `yaml:"oldSlug"`
+	NewSlug      []string `yaml:"newSlug"`
+	Departamento []string `yaml:"departamento"`
+	Categoria    []string `yaml:"categoria"`
+	Subcategoria []string `yaml:"subcategoria"`
+	From         []string `yaml:"from"`
+	To           []string `yaml:"to"`
+	// Slug and URL are the current slug and page of each product in a full
+	// catalog export, read by ParseCatalog.
+	Slug []string `yaml:"slug"`
+	URL  []string `yaml:"url"`
+}
+
+// DefaultAliases returns the aliases matching the catalog team export.
+func DefaultAliases() Aliases {
+	return Aliases{
+		Sku:          []string{"Sku", "Codigo"},
+		OldSlug:      []string{"Old Slug", "Slug Antigo"},
+		NewSlug:      []string{"New Slug", "Slug Novo"},
+		Departamento: []string{"Departamento", "Department"},
+		Categoria:    []string{"Categoria", "Category"},
+		Subcategoria: []string{"Subcategoria{n}", "Subcategory{n}"},
+		From:         []string{"Url{n}De", "De{n}", "Url{n}From"},
+		To:           []string{"Url{n}Para", "Para{n}", "Url{n}To"},
+		Slug:         []string{"Slug", "Url Key"},
+		URL:          []string{"Url", "Link"},
+	}
+}
+
+// Pair holds the positions of a numbered De/Para column pair.
+type Pair struct {
+	N    int
+	From int
+	To   int
+}
+
+// Layout is the position of every known column in a CSV file, resolved
+// from its header row. Optional columns missing from the header are -1.
+type Layout struct {
+	Header        []string
+	Sku           int
+	OldSlug       int
+	NewSlug       int
+	Departamento  int
+	Categoria     int
+	Subcategorias []int
+	Pairs         []Pair
+	Slug          int
+	URL           int
+}
+
+// URLPair is a De/Para pair read from a row.
+type URLPair struct {
+	N    int
+	From string
+	To   string
+	// FromDerived and ToDerived are set when the URL was built from the
+	// slug and category columns instead of read from the row.
+	FromDerived bool
+	ToDerived   bool
+}
+
+// Record is a row read through a Layout.
+type Record struct {
+	Sku           string
+	OldSlug       string
+	NewSlug       string
+	Departamento  string
+	Categoria     string
+	Subcategorias []string
+	Pairs         []URLPair
+	Slug          string
+	URL           string
+	Raw           []string
+}
+
+// Parse resolves the header row using the given aliases. It fails when the
+// Sku column or every De/Para pair is missing, or when a pair is incomplete.
+func Parse(header []string, aliases Aliases) (*Layout, error) {
+	layout, normalized, err := parse(header, aliases)
+	if err != nil {
+		return nil, err
+	}
+
+	from := findNumbered(normalized, aliases.From)
+	to := findNumbered(normalized, aliases.To)
+	for _, n := range sortedKeys(from) {
+		if _, ok := to[n]; !ok {
+			return nil, fmt.Errorf("columns: column %q has no matching Para column, accepted headers: %q", header[from[n]], aliases.To)
+		}
+		layout.Pairs = append(layout.Pairs, Pair{N: n, From: from[n], To: to[n]})
+	}
+	for _, n := range sortedKeys(to) {
+		if _, ok := from[n]; !ok {
+			return nil, fmt.Errorf("columns: column %q has no matching De column, accepted headers: %q", header[to[n]], aliases.From)
+		}
+	}
+	if len(layout.Pairs) == 0 {
+		return nil, fmt.Errorf("columns: missing De/Para url columns, accepted headers: %q and %q", aliases.From, aliases.To)
+	}
+
+	return layout, nil
+}
+
+// ParseCatalog resolves the header row of a full catalog export, listing
+// the current slug or URL of every product instead of De/Para pairs. It
+// fails when the Sku column or both the slug and URL columns are missing.
+func ParseCatalog(header []string, aliases Aliases) (*Layout, error) {
+	layout, _, err := parse(header, aliases)
+	if err != nil {
+		return nil, err
+	}
+	if layout.Slug < 0 && layout.URL < 0 {
+		return nil, fmt.Errorf("columns: missing slug and url columns, accepted headers: %q and %q", aliases.Slug, aliases.URL)
+	}
+	return layout, nil
+}
+
+// parse resolves the columns other than the De/Para pairs, returning the
+// normalized header as well.
+func parse(header []string, aliases Aliases) (*Layout, []string, error) {
+	normalized := make([]string, len(header))
+	for i, h := range header {
+		normalized[i] = normalize(h)
+	}
+
+	layout := &Layout{
+		Header:       header,
+		Sku:          find(normalized, aliases.Sku),
+		OldSlug:      find(normalized, aliases.OldSlug),
+		NewSlug:      find(normalized, aliases.NewSlug),
+		Departamento: find(normalized, aliases.Departamento),
+		Categoria:    find(normalized, aliases.Categoria),
+		Slug:         find(normalized, aliases.Slug),
+		URL:          find(normalized, aliases.URL),
+	}
+	if layout.Sku < 0 {
+		return nil, nil, fmt.Errorf("columns: missing required column sku, accepted headers: %q", aliases.Sku)
+	}
+
+	subcategorias := findNumbered(normalized, aliases.Subcategoria)
+	for _, n := range sortedKeys(subcategorias) {
+		layout.Subcategorias = append(layout.Subcategorias, subcategorias[n])
+	}
+	return layout, normalized, nil
+}
+
+// Record reads row through the layout. Missing cells, as in short rows,
+// are read as empty strings.
+func (l *Layout) Record(row []string) Record {
+	record := Record{
+		Sku:          cell(row, l.Sku),
+		OldSlug:      cell(row, l.OldSlug),
+		NewSlug:      cell(row, l.NewSlug),
+		Departamento: cell(row, l.Departamento),
+		Categoria:    cell(row, l.Categoria),
+		Slug:         cell(row, l.Slug),
+		URL:          cell(row, l.URL),
+		Raw:          row,
+	}
+	for _, i := range l.Subcategorias {
+		record.Subcategorias = append(record.Subcategorias, cell(row, i))
+	}
+	for _, p := range l.Pairs {
+		record.Pairs = append(record.Pairs, URLPair{N: p.N, From: cell(row, p.From), To: cell(row, p.To)})
+	}
+	return record
+}
+
+func cell(row []string, i int) string {
+	if i < 0 || i >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[i])
+}
+
+func normalize(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimPrefix(s, "\ufeff")) {
+		switch r {
+		case ' ', '-', '_', '\t':
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func find(header []string, aliases []string) int {
+	for _, alias := range aliases {
+		alias = normalize(alias)
+		for i, h := range header {
+			if h == alias {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// findNumbered returns the column position of every header matching one of the
+// numbered aliases, keyed by its number. The first alias matching a number wins.
+func findNumbered(header []string, aliases []string) map[int]int {
+	found := map[int]int{}
+	for _, alias := range aliases {
+		prefix, suffix, ok := strings.Cut(normalize(alias), indexPlaceholder)
+		if !ok {
+			continue
+		}
+		for i, h := range header {
+			if !strings.HasPrefix(h, prefix) || !strings.HasSuffix(h, suffix) || len(h) <= len(prefix)+len(suffix) {
+				continue
+			}
+			n, err := strconv.Atoi(h[len(prefix) : len(h)-len(suffix)])
+			if err != nil || n < 0 {
+				continue
+			}
+			if _, ok := found[n]; !ok {
+				found[n] = i
+			}
+		}
+	}
+	return found
+}
+
+func sortedKeys(m map[int]int) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
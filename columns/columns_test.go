@@ -0,0 +1,147 @@
+package columns_test
+
+import (
+	"testing"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/columns"
+	"github.com/stretchr/testify/require"
+)
+
+var exportHeader = []string{
+	"Sku", "Old Slug", "New Slug", "Departamento", "Categoria", "Subcategoria1", "Subcategoria2", "Subcategoria3",
+	"Url1De", "Url2De", "Url3De", "Url1Para", "Url2Para", "Url3Para",
+}
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		desc string
+
+		header           []string
+		aliases          func() columns.Aliases
+		errAssertionFunc require.ErrorAssertionFunc
+		validate         func(t *testing.T, l *columns.Layout)
+	}{
+		{
+			desc:             "catalog export layout",
+			header:           exportHeader,
+			aliases:          columns.DefaultAliases,
+			errAssertionFunc: require.NoError,
+			validate: func(t *testing.T, l *columns.Layout) {
+				require.Equal(t, 0, l.Sku)
+				require.Equal(t, 1, l.OldSlug)
+				require.Equal(t, 2, l.NewSlug)
+				require.Equal(t, []int{5, 6, 7}, l.Subcategorias)
+				require.Equal(t, []columns.Pair{
+					{N: 1, From: 8, To: 11},
+					{N: 2, From: 9, To: 12},
+					{N: 3, From: 10, To: 13},
+				}, l.Pairs)
+			},
+		},
+		{
+			desc:             "reordered columns, other case and more pairs",
+			header:           []string{"url5_para", "URL5DE", "sku", "url4 de", "url4 para"},
+			aliases:          columns.DefaultAliases,
+			errAssertionFunc: require.NoError,
+			validate: func(t *testing.T, l *columns.Layout) {
+				require.Equal(t, 2, l.Sku)
+				require.Equal(t, -1, l.OldSlug)
+				require.Equal(t, []columns.Pair{
+					{N: 4, From: 3, To: 4},
+					{N: 5, From: 1, To: 0},
+				}, l.Pairs)
+			},
+		},
+		{
+			desc:   "custom aliases",
+			header: []string{"Product", "Origem 1", "Destino 1"},
+			aliases: func() columns.Aliases {
+				aliases := columns.DefaultAliases()
+				aliases.Sku = []string{"Product"}
+				aliases.From = []string{"Origem {n}"}
+				aliases.To = []string{"Destino {n}"}
+				return aliases
+			},
+			errAssertionFunc: require.NoError,
+			validate: func(t *testing.T, l *columns.Layout) {
+				require.Equal(t, 0, l.Sku)
+				require.Equal(t, []columns.Pair{{N: 1, From: 1, To: 2}}, l.Pairs)
+			},
+		},
+		{
+			desc:             "missing sku",
+			header:           []string{"Url1De", "Url1Para"},
+			aliases:          columns.DefaultAliases,
+			errAssertionFunc: require.Error,
+		},
+		{
+			desc:             "missing url columns",
+			header:           []string{"Sku", "Old Slug"},
+			aliases:          columns.DefaultAliases,
+			errAssertionFunc: require.Error,
+		},
+		{
+			desc:             "De without Para",
+			header:           []string{"Sku", "Url1De", "Url1Para", "Url2De"},
+			aliases:          columns.DefaultAliases,
+			errAssertionFunc: require.Error,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			layout, err := columns.Parse(tC.header, tC.aliases())
+			tC.errAssertionFunc(t, err)
+			if tC.validate != nil {
+				tC.validate(t, layout)
+			}
+		})
+	}
+}
+
+func TestLayoutRecord(t *testing.T) {
+	layout, err := columns.Parse(exportHeader, columns.DefaultAliases())
+	require.NoError(t, err)
+
+	record := layout.Record([]string{
+		"0000000028014", "tamanho:g", "tamanho-g", "sem-categoria", "", "", "", "",
+		"https://www.cliquefarma.com.br/sem-categoria/tamanho:g", "", "",
+		"https://www.cliquefarma.com.br/sem-categoria/tamanho-g",
+	})
+
+	require.Equal(t, "0000000028014", record.Sku)
+	require.Equal(t, "tamanho:g", record.OldSlug)
+	require.Equal(t, "sem-categoria", record.Departamento)
+	require.Equal(t, []columns.URLPair{
+		{N: 1, From: "https://www.cliquefarma.com.br/sem-categoria/tamanho:g", To: "https://www.cliquefarma.com.br/sem-categoria/tamanho-g"},
+		{N: 2},
+		{N: 3},
+	}, record.Pairs)
+}
+
+func TestParseCatalog(t *testing.T) {
+	testCases := []struct {
+		desc string
+
+		header           []string
+		errAssertionFunc require.ErrorAssertionFunc
+		slug             int
+		url              int
+	}{
+		{desc: "slug and url", header: []string{"Codigo", "Departamento", "Url Key", "Link"}, errAssertionFunc: require.NoError, slug: 2, url: 3},
+		{desc: "slug only", header: []string{"Sku", "Slug"}, errAssertionFunc: require.NoError, slug: 1, url: -1},
+		{desc: "url only", header: []string{"Sku", "URL"}, errAssertionFunc: require.NoError, slug: -1, url: 1},
+		{desc: "neither", header: []string{"Sku", "Old Slug", "Url1De", "Url1Para"}, errAssertionFunc: require.Error},
+		{desc: "missing sku", header: []string{"Slug"}, errAssertionFunc: require.Error},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			layout, err := columns.ParseCatalog(tC.header, columns.DefaultAliases())
+			tC.errAssertionFunc(t, err)
+			if err == nil {
+				require.Equal(t, tC.slug, layout.Slug)
+				require.Equal(t, tC.url, layout.URL)
+				require.Empty(t, layout.Pairs)
+			}
+		})
+	}
+}
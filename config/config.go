@@ -0,0 +1,212 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/columns"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/result"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/rules"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/soft404"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/urltemplate"
+)
+
+const (
+	DefaultInput     = "products_with_special_chars.csv"
+	DefaultOutput    = "output.csv"
+	DefaultWorkers   = 21
+	DefaultQueueSize = 100
+	DefaultTimeout   = 30 * time.Second
+	DefaultMaxHops   = 10
+	DefaultUserAgent = "cliquefarmabot v1.0.0"
+	DefaultLog       = "prod"
+
+	DefaultRequestsPerSecond = 10
+	DefaultBurst             = 10
+	DefaultMaxInFlight       = 10
+
+	DefaultRetries         = 2
+	DefaultRetryBackoff    = 500 * time.Millisecond
+	DefaultRetryMaxBackoff = 10 * time.Second
+
+	DefaultProbeCacheTTL = 24 * time.Hour
+
+	DefaultMaxBodySize = 2 << 20
+
+	// DefaultVerifyOutput keeps the verify command from overwriting the analysis.
+	DefaultVerifyOutput = "verify.csv"
+)
+
+// Config holds every setting of a run. Values can come from a YAML file
+// and be overridden by command line flags.
+type Config struct {
+	Input  string `yaml:"input"`
+	Output string `yaml:"output"`
+	// Format of the output: csv, json or ndjson. Empty picks it from the
+	// extension of Output.
+	Format result.Format `yaml:"format"`
+	// Report is an HTML file summarizing the run, written when set.
+	Report    string        `yaml:"report"`
+	Workers   int           `yaml:"workers"`
+	QueueSize int           `yaml:"queueSize"`
+	Timeout   time.Duration `yaml:"timeout"`
+	MaxHops   int           `yaml:"maxHops"`
+	// HeadFirst sends HEAD requests when no page body is read, falling back
+	// to GET when the server rejects them.
+	HeadFirst bool `yaml:"headFirst"`
+	// MaxBodySize is the number of bytes read from each page, 0 for all.
+	MaxBodySize int    `yaml:"maxBodySize"`
+	BaseHost    string `yaml:"baseHost"`
+	// URLTemplate builds the De and Para URLs missing from a row out of its
+	// slug and category columns, like "{base}/{departamento}/{slug}".
+	URLTemplate string `yaml:"urlTemplate"`
+	UserAgent   string `yaml:"userAgent"`
+	Log         string `yaml:"log"`
+	// Politeness limits, applied to each host.
+	RequestsPerSecond float64 `yaml:"requestsPerSecond"`
+	Burst             int     `yaml:"burst"`
+	MaxInFlight       int     `yaml:"maxInFlight"`
+	RobotsCrawlDelay  bool    `yaml:"robotsCrawlDelay"`
+	// Retries of timeouts, reset connections, 429 and 503, waiting a jittered
+	// exponential backoff starting at RetryBackoff, or the Retry-After header.
+	Retries         int           `yaml:"retries"`
+	RetryBackoff    time.Duration `yaml:"retryBackoff"`
+	RetryMaxBackoff time.Duration `yaml:"retryMaxBackoff"`
+
+	// ProbeCache is a directory keeping the probes between runs, reused
+	// until older than ProbeCacheTTL. Every run fetches each URL once anyway.
+	ProbeCache    string        `yaml:"probeCache"`
+	ProbeCacheTTL time.Duration `yaml:"probeCacheTTL"`
+
+	// State is the file keeping the pairs already analyzed. Defaults to the
+	// output path with a ".state" suffix.
+	State  string `yaml:"state"`
+	Resume bool   `yaml:"resume"`
+
+	Columns columns.Aliases `yaml:"columns"`
+	// Rules classify each analyzed pair, the first matching one wins.
+	Rules []rules.Rule `yaml:"rules"`
+	// Soft404 tells which pages answering 200 are missing products.
+	Soft404 soft404.Config `yaml:"soft404"`
+}
+
+// Default returns the configuration used when nothing else is given.
+func Default() Config {
+	return Config{
+		Input:     DefaultInput,
+		Output:    DefaultOutput,
+		Workers:   DefaultWorkers,
+		QueueSize: DefaultQueueSize,
+		Timeout:   DefaultTimeout,
+		MaxHops:   DefaultMaxHops,
+		HeadFirst: true,
+		UserAgent: DefaultUserAgent,
+		Log:       DefaultLog,
+
+		RequestsPerSecond: DefaultRequestsPerSecond,
+		Burst:             DefaultBurst,
+		MaxInFlight:       DefaultMaxInFlight,
+		Retries:           DefaultRetries,
+		RetryBackoff:      DefaultRetryBackoff,
+		RetryMaxBackoff:   DefaultRetryMaxBackoff,
+		ProbeCacheTTL:     DefaultProbeCacheTTL,
+		MaxBodySize:       DefaultMaxBodySize,
+		Columns:           columns.DefaultAliases(),
+		Rules:             rules.Default(),
+		Soft404:           soft404.Default(),
+	}
+}
+
+// StatePath returns the path of the state file of the run.
+func (c Config) StatePath() string {
+	if c.State != "" {
+		return c.State
+	}
+	return c.Output + ".state"
+}
+
+// URLs returns the template of URLTemplate, nil when it is empty.
+func (c Config) URLs() (*urltemplate.Template, error) {
+	if c.URLTemplate == "" {
+		return nil, nil
+	}
+	template, err := urltemplate.Parse(c.URLTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	if template.Uses("base") && c.BaseHost == "" {
+		return nil, fmt.Errorf("config: url template %q needs a base host", c.URLTemplate)
+	}
+	return template, nil
+}
+
+// LoadFile reads a YAML file over cfg. Keys missing from the file keep
+// the values already present in cfg.
+func LoadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read config file: %w", err)
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("could not parse config file %q: %w", path, err)
+	}
+	return nil
+}
+
+// Validate checks the values that would make a run fail later on.
+func (c Config) Validate() error {
+	if c.Input == "" {
+		return errors.New("config: input file is required")
+	}
+	if c.Output == "" {
+		return errors.New("config: output file is required")
+	}
+	if _, err := result.FormatFor(c.Output, c.Format); err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	if c.Workers < 1 {
+		return fmt.Errorf("config: workers must be at least 1, got %d", c.Workers)
+	}
+	if c.QueueSize < 0 {
+		return fmt.Errorf("config: queue size can not be negative, got %d", c.QueueSize)
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("config: timeout can not be negative, got %s", c.Timeout)
+	}
+	if c.RequestsPerSecond < 0 {
+		return fmt.Errorf("config: requests per second can not be negative, got %v", c.RequestsPerSecond)
+	}
+	if c.MaxInFlight < 0 {
+		return fmt.Errorf("config: max in flight can not be negative, got %d", c.MaxInFlight)
+	}
+	if c.Retries < 0 {
+		return fmt.Errorf("config: retries can not be negative, got %d", c.Retries)
+	}
+	if c.RetryBackoff < 0 || c.RetryMaxBackoff < 0 {
+		return fmt.Errorf("config: retry backoff can not be negative, got %s and %s", c.RetryBackoff, c.RetryMaxBackoff)
+	}
+	if c.MaxBodySize < 0 {
+		return fmt.Errorf("config: max body size can not be negative, got %d", c.MaxBodySize)
+	}
+	if c.ProbeCache != "" && c.ProbeCacheTTL <= 0 {
+		return fmt.Errorf("config: probe cache ttl must be positive, got %s", c.ProbeCacheTTL)
+	}
+	if c.MaxHops < 0 {
+		return fmt.Errorf("config: max hops can not be negative, got %d", c.MaxHops)
+	}
+	if c.BaseHost != "" {
+		u, err := url.Parse(c.BaseHost)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("config: base host must be an absolute url like https://www.cliquefarma.com.br, got %q", c.BaseHost)
+		}
+	}
+	if _, err := c.URLs(); err != nil {
+		return err
+	}
+	return nil
+}
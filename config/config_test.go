@@ -0,0 +1,143 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/config"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/rules"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadFile(t *testing.T) {
+	testCases := []struct {
+		desc string
+
+		content          string
+		errAssertionFunc require.ErrorAssertionFunc
+		expected         func() config.Config
+	}{
+		{
+			desc:             "overriding only the keys present in the file",
+			errAssertionFunc: require.NoError,
+			content: `
+input: export.csv
+workers: 4
+timeout: 10s
+baseHost: https://staging.cliquefarma.com.br
+`,
+			expected: func() config.Config {
+				cfg := config.Default()
+				cfg.Input = "export.csv"
+				cfg.Workers = 4
+				cfg.Timeout = 10 * time.Second
+				cfg.BaseHost = "https://staging.cliquefarma.com.br"
+				return cfg
+			},
+		},
+		{
+			desc:             "rules replacing the defaults",
+			errAssertionFunc: require.NoError,
+			content: `
+rules:
+  - name: AMBOS_404
+    explanation: both answer 404
+    from:
+      finalStatus: ["404"]
+    to:
+      finalStatus: ["404"]
+`,
+			expected: func() config.Config {
+				cfg := config.Default()
+				cfg.Rules = []rules.Rule{{
+					Name:        "AMBOS_404",
+					Explanation: "both answer 404",
+					From:        rules.URL{FinalStatus: []string{"404"}},
+					To:          rules.URL{FinalStatus: []string{"404"}},
+				}}
+				return cfg
+			},
+		},
+		{
+			desc:             "invalid yaml",
+			errAssertionFunc: require.Error,
+			content:          "workers: [",
+			expected:         config.Default,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(tC.content), 0o600))
+
+			cfg := config.Default()
+			err := config.LoadFile(path, &cfg)
+			tC.errAssertionFunc(t, err)
+			if err == nil {
+				require.Equal(t, tC.expected(), cfg)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		desc string
+
+		change           func(cfg *config.Config)
+		errAssertionFunc require.ErrorAssertionFunc
+	}{
+		{
+			desc:             "default config is valid",
+			change:           func(cfg *config.Config) {},
+			errAssertionFunc: require.NoError,
+		},
+		{
+			desc:             "empty input",
+			change:           func(cfg *config.Config) { cfg.Input = "" },
+			errAssertionFunc: require.Error,
+		},
+		{
+			desc:             "no workers",
+			change:           func(cfg *config.Config) { cfg.Workers = 0 },
+			errAssertionFunc: require.Error,
+		},
+		{
+			desc:             "negative retries",
+			change:           func(cfg *config.Config) { cfg.Retries = -1 },
+			errAssertionFunc: require.Error,
+		},
+		{
+			desc:             "base host without scheme",
+			change:           func(cfg *config.Config) { cfg.BaseHost = "www.cliquefarma.com.br" },
+			errAssertionFunc: require.Error,
+		},
+		{
+			desc:             "url template without slug",
+			change:           func(cfg *config.Config) { cfg.URLTemplate = "https://www.cliquefarma.com.br/{departamento}" },
+			errAssertionFunc: require.Error,
+		},
+		{
+			desc:             "url template without base host",
+			change:           func(cfg *config.Config) { cfg.URLTemplate = "{base}/{slug}" },
+			errAssertionFunc: require.Error,
+		},
+		{
+			desc: "url template",
+			change: func(cfg *config.Config) {
+				cfg.BaseHost = "https://www.cliquefarma.com.br"
+				cfg.URLTemplate = "{base}/{departamento}/{slug}"
+			},
+			errAssertionFunc: require.NoError,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			cfg := config.Default()
+			tC.change(&cfg)
+			tC.errAssertionFunc(t, cfg.Validate())
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/config"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/crawl"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/graph"
+)
+
+// oldLink is a link of a crawled page to a De URL of the redirect map.
+type oldLink struct {
+	Sku   string
+	Page  string
+	Link  string
+	Text  string
+	Para  string
+	Depth int
+}
+
+// deURL is a De URL of the redirect map as linked from the crawled pages.
+type deURL struct {
+	skus []string
+	// from is the De URL as written in the input.
+	from string
+	// origin is the scheme and host of the seed the URL was moved to, empty
+	// when it is looked for as written.
+	origin string
+}
+
+// runCrawl visits the seed pages and the pages of the same hosts they link
+// to, and reports every link to a De URL of the input, so it can be changed
+// to its Para URL at the source.
+func runCrawl(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("crawl", flag.ContinueOnError)
+	seeds := fs.String("seeds", "", "comma separated pages to start from, the base host when empty")
+	input := fs.String("input", config.DefaultInput, "CSV file with the products whose De URLs are looked for")
+	output := fs.String("output", "", "CSV file to write the links to, standard output when empty")
+	depth := fs.Int("depth", 2, "links followed from the seed pages")
+	maxPages := fs.Int("max-pages", 1000, "pages visited at most, 0 for no limit")
+	configPath := fs.String("config", "", "YAML config file with the input column aliases, base host, limits and user agent")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg := config.Default()
+	if *configPath != "" {
+		if err := config.LoadFile(*configPath, &cfg); err != nil {
+			return err
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	var starts []string
+	for _, seed := range strings.Split(*seeds, ",") {
+		if seed = strings.TrimSpace(seed); seed != "" {
+			starts = append(starts, seed)
+		}
+	}
+	if len(starts) == 0 && cfg.BaseHost != "" {
+		starts = []string{strings.TrimSuffix(cfg.BaseHost, "/") + "/"}
+	}
+	if len(starts) == 0 {
+		return fmt.Errorf("crawl: -seeds is required without a base host")
+	}
+
+	layout, rows, err := readInput(*input, cfg.Columns)
+	if err != nil {
+		return err
+	}
+	edges, err := recordEdges(cfg, layout, rows)
+	if err != nil {
+		return err
+	}
+	g := graph.New(edges)
+
+	// The De URLs are looked for as written and on the hosts of the seeds,
+	// which can be a staging host the input does not mention.
+	origins := []string{""}
+	for _, seed := range starts {
+		if u, err := url.Parse(seed); err == nil && u.Host != "" {
+			origins = append(origins, u.Scheme+"://"+u.Host)
+		}
+	}
+	froms := map[string]*deURL{}
+	for _, edge := range edges {
+		for _, origin := range origins {
+			key := graph.Key(rebaseURL(edge.From, origin))
+			de, ok := froms[key]
+			if !ok {
+				de = &deURL{from: edge.From, origin: origin}
+				froms[key] = de
+			}
+			de.skus = appendSku(de.skus, edge.Sku)
+		}
+	}
+
+	opts := httpOptions(cfg)
+	fetch := func(ctx context.Context, pageURL string) (string, string, error) {
+		probe, err := FetchHttp(ctx, pageURL, http.MethodGet, opts)
+		probe.readContent(err == nil)
+		return probe.FinalURL, probe.Content, err
+	}
+
+	var links []oldLink
+	visited, failed := 0, 0
+	err = crawl.Crawl(ctx, starts, crawl.Config{
+		MaxDepth: *depth,
+		MaxPages: *maxPages,
+		Workers:  cfg.Workers,
+		Follow: func(link string) bool {
+			// De URLs only redirect to pages found some other way.
+			_, ok := froms[graph.Key(link)]
+			return !ok
+		},
+	}, fetch, func(page crawl.Page) {
+		visited++
+		if page.Err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "could not crawl %s: %v\n", page.URL, page.Err)
+		}
+		seen := map[string]bool{}
+		for _, link := range page.Links {
+			key := graph.Key(link.URL)
+			de, ok := froms[key]
+			if !ok || seen[key] {
+				continue
+			}
+			seen[key] = true
+			para, _ := g.Resolve(de.from)
+			links = append(links, oldLink{
+				Sku:   strings.Join(de.skus, ","),
+				Page:  page.FinalURL,
+				Link:  link.URL,
+				Text:  link.Text,
+				Para:  rebaseURL(para, de.origin),
+				Depth: page.Depth,
+			})
+		}
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "crawled %d pages, %d failed\n", visited, failed)
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("failed creating file: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+	if err := writeOldLinks(w, links); err != nil {
+		return err
+	}
+
+	if len(links) > 0 {
+		return fmt.Errorf("%d links to De urls in %d pages", len(links), visited)
+	}
+	return nil
+}
+
+func writeOldLinks(w io.Writer, links []oldLink) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"Sku", "Page", "Link", "Text", "Para", "Depth"})
+	for _, link := range links {
+		writer.Write([]string{link.Sku, link.Page, link.Link, link.Text, link.Para, strconv.Itoa(link.Depth)})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed writing links: %w", err)
+	}
+	return nil
+}
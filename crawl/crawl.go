@@ -0,0 +1,220 @@
+package crawl
+
+import (
+	"context"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/seo"
+)
+
+var (
+	comment   = regexp.MustCompile(`(?s)<!--.*?-->`)
+	script    = regexp.MustCompile(`(?is)<(script|style|template)\b.*?</(script|style|template)\s*>`)
+	anchor    = regexp.MustCompile(`(?is)<a\b([^>]*)>(.*?)</a\s*>`)
+	baseTag   = regexp.MustCompile(`(?is)<base\b[^>]*>`)
+	tag       = regexp.MustCompile(`(?s)<[^>]*>`)
+	blankRuns = regexp.MustCompile(`\s+`)
+)
+
+// Link is an anchor of a page.
+type Link struct {
+	// URL is the absolute URL of the anchor, without fragment.
+	URL string
+	// Text is the visible text of the anchor.
+	Text string
+}
+
+// Page is a page visited by Crawl.
+type Page struct {
+	URL string
+	// FinalURL is the URL reached after the redirects of URL.
+	FinalURL string
+	// Depth is the number of links followed from a seed, 0 for the seeds.
+	Depth int
+	// Links are left empty when the page redirects to another host or to a
+	// page already visited.
+	Links []Link
+	Err   error
+}
+
+// Fetcher returns the URL reached requesting pageURL and its HTML.
+type Fetcher func(ctx context.Context, pageURL string) (finalURL string, body string, err error)
+
+// Config bounds a crawl.
+type Config struct {
+	// MaxDepth is the number of links followed from the seeds.
+	MaxDepth int
+	// MaxPages is the number of pages visited, 0 for no limit.
+	MaxPages int
+	// Workers is the number of pages fetched at once, at least 1.
+	Workers int
+	// Follow, when set, tells whether a link of the crawled hosts is
+	// visited.
+	Follow func(link string) bool
+}
+
+// Crawl visits the seeds and the pages of their hosts they link to, level by
+// level, up to cfg.MaxDepth links away. visit is called with every page in
+// the order they were found, never concurrently.
+func Crawl(ctx context.Context, seeds []string, cfg Config, fetch Fetcher, visit func(Page)) error {
+	hosts := map[string]bool{}
+	visited := map[string]bool{}
+	var level []string
+	for _, seed := range seeds {
+		u, err := url.Parse(strings.TrimSpace(seed))
+		if err != nil || u.Host == "" {
+			continue
+		}
+		hosts[strings.ToLower(u.Host)] = true
+		if k := key(u.String()); !visited[k] {
+			visited[k] = true
+			level = append(level, u.String())
+		}
+	}
+
+	// reached are the final URLs whose links were already read, pages of
+	// different URLs redirecting to the same one.
+	reached := map[string]bool{}
+	pages := len(level)
+	for depth := 0; len(level) > 0; depth++ {
+		if cfg.MaxPages > 0 && len(level) > cfg.MaxPages {
+			level = level[:cfg.MaxPages]
+		}
+		fetched := fetchAll(ctx, level, depth, cfg.Workers, fetch)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var next []string
+		for _, page := range fetched {
+			if final := key(page.FinalURL); reached[final] || !sameHost(hosts, page.FinalURL) {
+				page.Links = nil
+			} else if page.Err == nil {
+				reached[final] = true
+			}
+			visit(page)
+			if depth >= cfg.MaxDepth {
+				continue
+			}
+			for _, link := range page.Links {
+				k := key(link.URL)
+				if visited[k] || !sameHost(hosts, link.URL) || (cfg.Follow != nil && !cfg.Follow(link.URL)) {
+					continue
+				}
+				if cfg.MaxPages > 0 && pages >= cfg.MaxPages {
+					break
+				}
+				visited[k] = true
+				pages++
+				next = append(next, link.URL)
+			}
+		}
+		level = next
+	}
+	return nil
+}
+
+// fetchAll fetches urls with the given number of workers, returning the
+// pages in the order of urls.
+func fetchAll(ctx context.Context, urls []string, depth int, workers int, fetch Fetcher) []Page {
+	if workers < 1 {
+		workers = 1
+	}
+	pages := make([]Page, len(urls))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				page := Page{URL: urls[i], FinalURL: urls[i], Depth: depth}
+				finalURL, body, err := fetch(ctx, urls[i])
+				if finalURL != "" {
+					page.FinalURL = finalURL
+				}
+				page.Err = err
+				if err == nil {
+					page.Links = Links(body, page.FinalURL)
+				}
+				pages[i] = page
+			}
+		}()
+	}
+	for i := range urls {
+		if ctx.Err() != nil {
+			break
+		}
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return pages
+}
+
+// Links returns the http and https links of the anchors of an HTML page,
+// resolved against its base URL, pageURL unless the page sets one.
+func Links(body string, pageURL string) []Link {
+	body = comment.ReplaceAllString(body, "")
+	body = script.ReplaceAllString(body, "")
+
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil
+	}
+	if tag := baseTag.FindString(body); tag != "" {
+		if href, ok := attrHref(tag); ok {
+			if ref, err := url.Parse(href); err == nil {
+				base = base.ResolveReference(ref)
+			}
+		}
+	}
+
+	var links []Link
+	for _, match := range anchor.FindAllStringSubmatch(body, -1) {
+		href, ok := attrHref(match[1])
+		if !ok {
+			continue
+		}
+		ref, err := url.Parse(href)
+		if err != nil {
+			continue
+		}
+		u := base.ResolveReference(ref)
+		if u.Scheme != "http" && u.Scheme != "https" {
+			continue
+		}
+		u.Fragment = ""
+		u.RawFragment = ""
+		text := html.UnescapeString(tag.ReplaceAllString(match[2], " "))
+		links = append(links, Link{URL: u.String(), Text: strings.TrimSpace(blankRuns.ReplaceAllString(text, " "))})
+	}
+	return links
+}
+
+func attrHref(tag string) (string, bool) {
+	href := seo.Attributes(tag)["href"]
+	return href, href != ""
+}
+
+func sameHost(hosts map[string]bool, rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && hosts[strings.ToLower(u.Host)]
+}
+
+// key is rawURL with its scheme and host lowercased and without fragment.
+func key(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.Fragment = ""
+	u.RawFragment = ""
+	return u.String()
+}
@@ -0,0 +1,128 @@
+package crawl_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/crawl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinks(t *testing.T) {
+	testCases := []struct {
+		desc string
+
+		body  string
+		links []crawl.Link
+	}{
+		{
+			desc: "relative and absolute",
+			body: `<a href="/b">B</a><A HREF='c?x=1#top'>C</A><a class="x" href=https://other.com/d>D</a>`,
+			links: []crawl.Link{
+				{URL: "https://www.cliquefarma.com.br/b", Text: "B"},
+				{URL: "https://www.cliquefarma.com.br/dir/c?x=1", Text: "C"},
+				{URL: "https://other.com/d", Text: "D"},
+			},
+		},
+		{
+			desc:  "text without tags nor entities",
+			body:  "<a href=\"/b\">\n  <img src=\"b.png\"> Dipirona &amp; <b>cia</b>\n</a>",
+			links: []crawl.Link{{URL: "https://www.cliquefarma.com.br/b", Text: "Dipirona & cia"}},
+		},
+		{
+			desc:  "base tag",
+			body:  `<head><base href="/other/"></head><a href="b">B</a>`,
+			links: []crawl.Link{{URL: "https://www.cliquefarma.com.br/other/b", Text: "B"}},
+		},
+		{
+			desc: "comments, scripts and other schemes left out",
+			body: `<!-- <a href="/old">old</a> --><script>x = '<a href="/js">js</a>'</script>` +
+				`<a href="mailto:a@b.com">mail</a><a href="javascript:void(0)">js</a><a name="x">no href</a><a href="#top">top</a>`,
+			links: []crawl.Link{{URL: "https://www.cliquefarma.com.br/dir/page", Text: "top"}},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			require.Equal(t, tC.links, crawl.Links(tC.body, "https://www.cliquefarma.com.br/dir/page"))
+		})
+	}
+}
+
+func TestCrawl(t *testing.T) {
+	site := map[string]string{
+		"https://a.com/":      `<a href="/one">1</a><a href="/two">2</a><a href="https://b.com/">b</a>`,
+		"https://a.com/one":   `<a href="/three">3</a><a href="/">home</a><a href="/old">old</a>`,
+		"https://a.com/two":   `<a href="/three#reviews">3</a>`,
+		"https://a.com/three": `<a href="/four">4</a>`,
+		"https://a.com/four":  ``,
+		"https://b.com/":      `<a href="https://a.com/five">5</a>`,
+	}
+	redirects := map[string]string{"https://a.com/two": "https://b.com/"}
+
+	var mu sync.Mutex
+	var fetched []string
+	fetch := func(ctx context.Context, pageURL string) (string, string, error) {
+		mu.Lock()
+		fetched = append(fetched, pageURL)
+		mu.Unlock()
+		if final, ok := redirects[pageURL]; ok {
+			return final, site[final], nil
+		}
+		body, ok := site[pageURL]
+		if !ok {
+			return pageURL, "", errors.New("404")
+		}
+		return pageURL, body, nil
+	}
+
+	testCases := []struct {
+		desc string
+
+		cfg   crawl.Config
+		pages []string
+	}{
+		{
+			desc:  "depth 1",
+			cfg:   crawl.Config{MaxDepth: 1, Workers: 2},
+			pages: []string{"https://a.com/ 0 3", "https://a.com/one 1 3", "https://a.com/two 1 0"},
+		},
+		{
+			desc: "depth 2, following links to other hosts neither",
+			cfg:  crawl.Config{MaxDepth: 2, Workers: 2},
+			pages: []string{
+				"https://a.com/ 0 3", "https://a.com/one 1 3", "https://a.com/two 1 0",
+				"https://a.com/three 2 1", "https://a.com/old 2 0 404",
+			},
+		},
+		{
+			desc:  "not following",
+			cfg:   crawl.Config{MaxDepth: 2, Follow: func(link string) bool { return !strings.HasSuffix(link, "/old") }},
+			pages: []string{"https://a.com/ 0 3", "https://a.com/one 1 3", "https://a.com/two 1 0", "https://a.com/three 2 1"},
+		},
+		{
+			desc:  "max pages",
+			cfg:   crawl.Config{MaxDepth: 5, MaxPages: 2},
+			pages: []string{"https://a.com/ 0 3", "https://a.com/one 1 3"},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			fetched = nil
+			var pages []string
+			err := crawl.Crawl(context.Background(), []string{"https://a.com/"}, tC.cfg, fetch, func(page crawl.Page) {
+				s := fmt.Sprintf("%s %d %d", page.URL, page.Depth, len(page.Links))
+				if page.Err != nil {
+					s += " " + page.Err.Error()
+				}
+				pages = append(pages, s)
+			})
+			require.NoError(t, err)
+			require.Equal(t, tC.pages, pages)
+			require.Len(t, fetched, len(pages), "each page fetched once")
+		})
+	}
+}
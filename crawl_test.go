@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunCrawl(t *testing.T) {
+	pages := map[string]string{
+		"/":             `<a href="/medicamentos">Medicamentos</a><a href="/new-a">A</a>`,
+		"/medicamentos": `<a href="/old-a">Dipirona</a><a href="/old-a#reviews">Dipirona</a><a href="/old-b">Dorflex</a><a href="/deep">deep</a>`,
+		"/deep":         `<a href="/old-c">too deep</a>`,
+		"/new-a":        `<a href="/medicamentos">back</a>`,
+	}
+	var mu sync.Mutex
+	var requested []string
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.Path)
+		mu.Unlock()
+		if r.URL.Path == "/old-a" {
+			http.Redirect(w, r, "/new-a", http.StatusMovedPermanently)
+			return
+		}
+		body, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, "<html><body>%s</body></html>", body)
+	}))
+	t.Cleanup(site.Close)
+
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.csv")
+	output := filepath.Join(dir, "links.csv")
+	require.NoError(t, os.WriteFile(input, []byte(inputHeader+
+		fmt.Sprintf("1,old-a,new-a,,,,,,%[1]s/old-a,,,%[1]s/new-a,,\n", site.URL)+
+		fmt.Sprintf("2,old-b,new-b,,,,,,%[1]s/old-b,,,%[1]s/new-b,,\n", site.URL)+
+		fmt.Sprintf("3,new-b,final-b,,,,,,%[1]s/new-b,,,%[1]s/final-b,,\n", site.URL)+
+		fmt.Sprintf("4,old-c,new-c,,,,,,%[1]s/old-c,,,%[1]s/new-c,,\n", site.URL),
+	), 0o600))
+
+	err := runCrawl(context.Background(), []string{"-seeds", site.URL + "/", "-input", input, "-output", output, "-depth", "1"})
+	require.EqualError(t, err, "2 links to De urls in 3 pages")
+
+	require.Equal(t, [][]string{
+		{"Sku", "Page", "Link", "Text", "Para", "Depth"},
+		{"1", site.URL + "/medicamentos", site.URL + "/old-a", "Dipirona", site.URL + "/new-a", "1"},
+		{"2", site.URL + "/medicamentos", site.URL + "/old-b", "Dorflex", site.URL + "/final-b", "1"},
+	}, readOutput(t, output))
+	require.NotContains(t, requested, "/old-a", "De URLs are not followed")
+	require.NotContains(t, requested, "/deep", "pages deeper than -depth are not visited")
+
+	err = runCrawl(context.Background(), []string{"-input", input})
+	require.EqualError(t, err, "crawl: -seeds is required without a base host")
+}
+
+func TestRunCrawlOtherHost(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `<html><body><a href="/old-a">Dipirona</a><a href="https://www.cliquefarma.com.br/old-b">Dorflex</a></body></html>`)
+	}))
+	t.Cleanup(site.Close)
+
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.csv")
+	output := filepath.Join(dir, "links.csv")
+	configPath := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(input, []byte(inputHeader+
+		"1,old-a,new-a,,,,,,https://www.cliquefarma.com.br/old-a,,,https://www.cliquefarma.com.br/new-a,,\n"+
+		"2,old-b,new-b,,,,,,https://www.cliquefarma.com.br/old-b,,,https://www.cliquefarma.com.br/new-b,,\n",
+	), 0o600))
+	require.NoError(t, os.WriteFile(configPath, []byte("baseHost: https://staging.cliquefarma.com.br\n"), 0o600))
+
+	// The seed is on neither the host of the input nor the base host.
+	err := runCrawl(context.Background(), []string{"-seeds", site.URL + "/", "-input", input, "-output", output, "-config", configPath})
+	require.EqualError(t, err, "2 links to De urls in 1 pages")
+
+	require.Equal(t, [][]string{
+		{"Sku", "Page", "Link", "Text", "Para", "Depth"},
+		{"1", site.URL + "/", site.URL + "/old-a", "Dipirona", site.URL + "/new-a", "0"},
+		{"2", site.URL + "/", "https://www.cliquefarma.com.br/old-b", "Dorflex", "https://www.cliquefarma.com.br/new-b", "0"},
+	}, readOutput(t, output))
+}
+
+func TestRunCrawlInvalidConfig(t *testing.T) {
+	configPath := writeInvalidConfig(t, t.TempDir())
+	err := runCrawl(context.Background(), []string{"-seeds", "https://www.cliquefarma.com.br/", "-config", configPath})
+	require.EqualError(t, err, "config: timeout can not be negative, got -1s")
+}
+
+func TestRunCrawlEncodedURLs(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `<html><body><a href="/camiseta-tamanho%3Ag">G</a><a href="/camiseta-tamanho:m">M</a></body></html>`)
+	}))
+	t.Cleanup(site.Close)
+
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.csv")
+	output := filepath.Join(dir, "links.csv")
+	require.NoError(t, os.WriteFile(input, []byte(inputHeader+
+		fmt.Sprintf("1,camiseta-tamanho:g,camiseta-tamanho-g,,,,,,%[1]s/camiseta-tamanho:g,,,%[1]s/camiseta-tamanho-g,,\n", site.URL)+
+		fmt.Sprintf("2,camiseta-tamanho:m,camiseta-tamanho-m,,,,,,%[1]s/camiseta-tamanho%%3Am,,,%[1]s/camiseta-tamanho-m,,\n", site.URL),
+	), 0o600))
+
+	err := runCrawl(context.Background(), []string{"-seeds", site.URL + "/", "-input", input, "-output", output})
+	require.EqualError(t, err, "2 links to De urls in 1 pages", "encoded and plain spellings match")
+
+	records := readOutput(t, output)
+	require.Len(t, records, 3)
+	require.Equal(t, []string{"1", site.URL + "/camiseta-tamanho%3Ag", site.URL + "/camiseta-tamanho-g"}, []string{records[1][0], records[1][2], records[1][4]})
+	require.Equal(t, []string{"2", site.URL + "/camiseta-tamanho:m", site.URL + "/camiseta-tamanho-m"}, []string{records[2][0], records[2][2], records[2][4]})
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/export"
+)
+
+// runExport reads the analysis output and writes the redirects of the rows
+// with the wanted status as server configuration.
+func runExport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	input := fs.String("input", "output.csv", "analysis output to read the redirects from")
+	output := fs.String("output", "", "file to write the rules to, standard output when empty")
+	format := fs.String("format", string(export.NginxMap), fmt.Sprintf("rules format, one of %q", export.Formats()))
+	status := fs.String("status", "REDIRECIONAR", "comma separated statuses of the rows exported")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	redirects, err := readRedirects(*input, strings.Split(*status, ","))
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("failed creating file: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	written, err := export.Write(w, export.Format(*format), redirects)
+	if err != nil {
+		return err
+	}
+	if *output != "" {
+		fmt.Printf("exported %d of %d redirects as %s to %s\n", written, len(redirects), *format, *output)
+	}
+	return ctx.Err()
+}
+
+// readRedirects reads the De and Para columns of the analysis rows whose
+// Status is one of statuses.
+func readRedirects(path string, statuses []string) ([]export.Redirect, error) {
+	rows, err := readAnalysis(path, statuses, "De", "Para")
+	if err != nil {
+		return nil, err
+	}
+
+	redirects := make([]export.Redirect, 0, len(rows))
+	for _, row := range rows {
+		redirects = append(redirects, export.Redirect{From: row["De"], To: row["Para"]})
+	}
+	return redirects, nil
+}
+
+// readAnalysis reads the rows of an analysis output whose Status is one of
+// statuses, keyed by column name. The Status column and every column in
+// required must be present.
+func readAnalysis(path string, statuses []string, required ...string) ([]map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed opening input file: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed reading input header: %w", err)
+	}
+
+	index := map[string]int{}
+	for i, name := range header {
+		index[name] = i
+	}
+	for _, name := range append([]string{"Status"}, required...) {
+		if _, ok := index[name]; !ok {
+			return nil, fmt.Errorf("invalid analysis file %s: missing column %q", path, name)
+		}
+	}
+
+	wanted := map[string]bool{}
+	for _, status := range statuses {
+		wanted[strings.TrimSpace(status)] = true
+	}
+
+	var rows []map[string]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed reading %s: %w", path, err)
+		}
+		if len(record) < len(header) || !wanted[record[index["Status"]]] {
+			continue
+		}
+
+		row := make(map[string]string, len(header))
+		for name, i := range index {
+			row[name] = record[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
@@ -0,0 +1,210 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Format is a server configuration syntax redirects can be written in.
+type Format string
+
+const (
+	// NginxMap writes a map block and the return statement using it, to be
+	// included in the http context.
+	NginxMap Format = "nginx-map"
+	// NginxRewrite writes one rewrite directive per redirect, to be included
+	// in a server block.
+	NginxRewrite Format = "nginx-rewrite"
+	// ApacheRewrite writes mod_rewrite rules, valid both in a virtual host and
+	// in a .htaccess file.
+	ApacheRewrite Format = "apache-rewrite"
+	// ApacheRedirect writes mod_alias Redirect lines. Redirect matches path
+	// prefixes, so rules are written longest path first.
+	ApacheRedirect Format = "apache-redirect"
+	// Redirects writes a Netlify and Cloudflare Pages _redirects file.
+	Redirects Format = "redirects"
+)
+
+// Formats lists every supported format.
+func Formats() []Format {
+	return []Format{NginxMap, NginxRewrite, ApacheRewrite, ApacheRedirect, Redirects}
+}
+
+// Redirect is a permanent redirect from a URL to another.
+type Redirect struct {
+	From string
+	To   string
+}
+
+// rule is a redirect ready to be written: the decoded path matched on the
+// old URL, and the target, as a path when it is on the same host.
+type rule struct {
+	path   string
+	target string
+}
+
+// Write writes redirects in format. Redirects whose From has a query string
+// or was already written are skipped, as rules only match paths.
+// It returns how many redirects were written.
+func Write(w io.Writer, format Format, redirects []Redirect) (int, error) {
+	rules, err := toRules(redirects)
+	if err != nil {
+		return 0, err
+	}
+
+	bw := bufio.NewWriter(w)
+	switch format {
+	case NginxMap:
+		writeNginxMap(bw, rules)
+	case NginxRewrite:
+		writeNginxRewrite(bw, rules)
+	case ApacheRewrite:
+		writeApacheRewrite(bw, rules)
+	case ApacheRedirect:
+		writeApacheRedirect(bw, rules)
+	case Redirects:
+		writeRedirects(bw, rules)
+	default:
+		return 0, fmt.Errorf("export: unknown format %q, expected one of %q", format, Formats())
+	}
+	return len(rules), bw.Flush()
+}
+
+func toRules(redirects []Redirect) ([]rule, error) {
+	seen := map[string]bool{}
+	rules := make([]rule, 0, len(redirects))
+	for _, r := range redirects {
+		from, err := url.Parse(r.From)
+		if err != nil {
+			return nil, fmt.Errorf("export: invalid url %q: %w", r.From, err)
+		}
+		to, err := url.Parse(r.To)
+		if err != nil {
+			return nil, fmt.Errorf("export: invalid url %q: %w", r.To, err)
+		}
+		if from.RawQuery != "" || seen[from.Path] {
+			continue
+		}
+		seen[from.Path] = true
+
+		target := to.String()
+		if to.Host == "" || strings.EqualFold(to.Host, from.Host) {
+			target = to.EscapedPath()
+			if to.RawQuery != "" {
+				target += "?" + to.RawQuery
+			}
+		}
+		rules = append(rules, rule{path: pathOrRoot(from.Path), target: target})
+	}
+	return rules, nil
+}
+
+func pathOrRoot(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+// nginxString quotes s for nginx.
+func nginxString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+// nginxTarget quotes a redirect target for nginx. Targets are read with
+// variables and there is no escape for '$', so it is percent encoded.
+func nginxTarget(s string) string {
+	return nginxString(strings.ReplaceAll(s, "$", "%24"))
+}
+
+func writeNginxMap(w io.Writer, rules []rule) {
+	fmt.Fprintln(w, "map $uri $redirect_target {")
+	fmt.Fprintln(w, "    default \"\";")
+	for _, r := range rules {
+		fmt.Fprintf(w, "    %s %s;\n", nginxString(r.path), nginxTarget(r.target))
+	}
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "# Inside the server block:")
+	fmt.Fprintln(w, "# if ($redirect_target) {")
+	fmt.Fprintln(w, "#     return 301 $redirect_target;")
+	fmt.Fprintln(w, "# }")
+}
+
+func writeNginxRewrite(w io.Writer, rules []rule) {
+	for _, r := range rules {
+		// The regex is quoted, so '$' needs no other escape than QuoteMeta's.
+		pattern := `"^` + strings.ReplaceAll(regexp.QuoteMeta(r.path), `"`, `\"`) + `$"`
+		fmt.Fprintf(w, "rewrite %s %s permanent;\n", pattern, nginxTarget(r.target))
+	}
+}
+
+// apacheString quotes s for Apache configuration files.
+func apacheString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+// apacheSubstitution escapes the back-references of a RewriteRule target.
+func apacheSubstitution(s string) string {
+	s = strings.ReplaceAll(s, "$", `\$`)
+	s = strings.ReplaceAll(s, "%", `\%`)
+	return s
+}
+
+func writeApacheRewrite(w io.Writer, rules []rule) {
+	fmt.Fprintln(w, "RewriteEngine On")
+	for _, r := range rules {
+		// .htaccess files see the path without its leading slash.
+		pattern := "^/?" + regexp.QuoteMeta(strings.TrimPrefix(r.path, "/")) + "$"
+		fmt.Fprintf(w, "RewriteRule %s %s [R=301,L,NE]\n", apacheString(pattern), apacheString(apacheSubstitution(r.target)))
+	}
+}
+
+func writeApacheRedirect(w io.Writer, rules []rule) {
+	sorted := make([]rule, len(rules))
+	copy(sorted, rules)
+	// Longest first, so a path is not caught by the rule of one of its prefixes.
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i].path) > len(sorted[j].path)
+	})
+	for _, r := range sorted {
+		fmt.Fprintf(w, "Redirect 301 %s %s\n", apacheString(r.path), apacheString(r.target))
+	}
+}
+
+// redirectsPath escapes the characters the _redirects syntax gives a meaning
+// to: ':' starts a placeholder, '*' is a splat and spaces separate fields.
+var redirectsPath = strings.NewReplacer(
+	":", "%3A",
+	"*", "%2A",
+	" ", "%20",
+	"\t", "%09",
+	"#", "%23",
+)
+
+func writeRedirects(w io.Writer, rules []rule) {
+	for _, r := range rules {
+		target := r.target
+		if i := strings.Index(target, "://"); i >= 0 {
+			// Keep the scheme separator of absolute targets.
+			rest := target[i+3:]
+			host, path := rest, ""
+			if j := strings.Index(rest, "/"); j >= 0 {
+				host, path = rest[:j], rest[j:]
+			}
+			target = target[:i+3] + host + redirectsPath.Replace(path)
+		} else {
+			target = redirectsPath.Replace(target)
+		}
+		fmt.Fprintf(w, "%s %s 301\n", redirectsPath.Replace(r.path), target)
+	}
+}
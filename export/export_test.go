@@ -0,0 +1,97 @@
+package export_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/export"
+	"github.com/stretchr/testify/require"
+)
+
+var redirects = []export.Redirect{
+	{
+		From: "https://www.cliquefarma.com.br/sem-categoria/scrub-azul-tamanho:g",
+		To:   "https://www.cliquefarma.com.br/sem-categoria/scrub-azul-tamanho-g",
+	},
+	{
+		From: "https://www.cliquefarma.com.br/sem-categoria/preço$10",
+		To:   "https://loja.cliquefarma.com.br/preco-$10",
+	},
+	{
+		From: "https://www.cliquefarma.com.br/sem-categoria/scrub-azul-tamanho:g",
+		To:   "https://www.cliquefarma.com.br/duplicated",
+	},
+	{
+		From: "https://www.cliquefarma.com.br/busca?q=scrub",
+		To:   "https://www.cliquefarma.com.br/scrub",
+	},
+}
+
+func TestWrite(t *testing.T) {
+	testCases := []struct {
+		desc string
+
+		format   export.Format
+		expected string
+	}{
+		{
+			desc:   "nginx map",
+			format: export.NginxMap,
+			expected: `map $uri $redirect_target {
+    default "";
+    "/sem-categoria/scrub-azul-tamanho:g" "/sem-categoria/scrub-azul-tamanho-g";
+    "/sem-categoria/preço$10" "https://loja.cliquefarma.com.br/preco-%2410";
+}
+
+# Inside the server block:
+# if ($redirect_target) {
+#     return 301 $redirect_target;
+# }
+`,
+		},
+		{
+			desc:   "nginx rewrite",
+			format: export.NginxRewrite,
+			expected: `rewrite "^/sem-categoria/scrub-azul-tamanho:g$" "/sem-categoria/scrub-azul-tamanho-g" permanent;
+rewrite "^/sem-categoria/preço\$10$" "https://loja.cliquefarma.com.br/preco-%2410" permanent;
+`,
+		},
+		{
+			desc:   "apache rewrite",
+			format: export.ApacheRewrite,
+			expected: `RewriteEngine On
+RewriteRule "^/?sem-categoria/scrub-azul-tamanho:g$" "/sem-categoria/scrub-azul-tamanho-g" [R=301,L,NE]
+RewriteRule "^/?sem-categoria/preço\\$10$" "https://loja.cliquefarma.com.br/preco-\\$10" [R=301,L,NE]
+`,
+		},
+		{
+			desc:   "apache redirect",
+			format: export.ApacheRedirect,
+			expected: `Redirect 301 "/sem-categoria/scrub-azul-tamanho:g" "/sem-categoria/scrub-azul-tamanho-g"
+Redirect 301 "/sem-categoria/preço$10" "https://loja.cliquefarma.com.br/preco-$10"
+`,
+		},
+		{
+			desc:   "_redirects",
+			format: export.Redirects,
+			expected: `/sem-categoria/scrub-azul-tamanho%3Ag /sem-categoria/scrub-azul-tamanho-g 301
+/sem-categoria/preço$10 https://loja.cliquefarma.com.br/preco-$10 301
+`,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			var out strings.Builder
+			written, err := export.Write(&out, tC.format, redirects)
+			require.NoError(t, err)
+			require.Equal(t, 2, written)
+			require.Equal(t, tC.expected, out.String())
+		})
+	}
+}
+
+func TestWriteUnknownFormat(t *testing.T) {
+	var out strings.Builder
+	_, err := export.Write(&out, export.Format("iis"), redirects)
+	require.Error(t, err)
+}
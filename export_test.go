@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunExport(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "output.csv")
+	output := filepath.Join(dir, "_redirects")
+	require.NoError(t, os.WriteFile(input, []byte(
+		"Sku,De,Para,Status,De Status,Para Status\n"+
+			"1,https://www.cliquefarma.com.br/a/tamanho:g,https://www.cliquefarma.com.br/a/tamanho-g,REDIRECIONAR,200,404\n"+
+			"2,https://www.cliquefarma.com.br/b:1,https://www.cliquefarma.com.br/b-1,ANALISAR,200,200\n",
+	), 0o600))
+
+	err := runExport(context.Background(), []string{"-input", input, "-output", output, "-format", "redirects"})
+	require.NoError(t, err)
+
+	got, err := os.ReadFile(output)
+	require.NoError(t, err)
+	require.Equal(t, "/a/tamanho%3Ag /a/tamanho-g 301\n", string(got))
+}
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/config"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/logger"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/metadata"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/product"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/result"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/rules"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/seo"
+
+	inputhttp "github.com/castmetal/cliquefarma-analize-redirect-csv/http"
+)
+
+var (
+	errTooManyHops   = errors.New("too many redirects")
+	errRedirectLoop  = errors.New("redirect loop")
+	errEmptyLocation = errors.New("redirect without location")
+	// errStatus is returned for a final answer other than 200 or 206, which
+	// the status already tells.
+	errStatus = errors.New("unexpected status")
+)
+
+// Hop is one response of a redirect chain.
+type Hop struct {
+	URL        string
+	StatusCode int
+	Location   string
+	// Duration is the time taken by the hop, retries included.
+	Duration time.Duration
+}
+
+// Probe is what a URL answered. StatusCode is the status of the URL itself,
+// while FinalURL and FinalStatusCode are the ones reached after following
+// every redirect in Hops. Failure is set, and the status left at 0, when the
+// last request got no response at all.
+type Probe struct {
+	URL        string
+	StatusCode int
+	// Method is the one of the last request, GET when HEAD was rejected.
+	Method          string
+	FinalURL        string
+	FinalStatusCode int
+	Failure         string
+	Attempts        int
+	Duration        time.Duration
+	// Err is the error FetchHttp returned along with the probe.
+	Err  error `json:"-"`
+	Hops []Hop
+	Body io.ReadCloser `json:"-"`
+	// Content is the body, once read by readContent.
+	Content string
+	// Soft404 is why a page answering 200 was taken as missing, its final
+	// status then being 404.
+	Soft404 string
+	// Tags are the canonical link, robots directives and hreflang alternates
+	// of the end of the chain, the X-Robots-Tag headers set by readBody and
+	// the rest once the body is inspected.
+	Tags seo.Tags
+	// Page is the product shown at the end of the chain, once the body is
+	// inspected.
+	Page product.Page
+}
+
+// cachedErrors are the errors telling what a probe found, kept through the
+// probe cache so errors.Is still holds for them.
+var cachedErrors = []error{errStatus, errRedirectLoop, errTooManyHops, errEmptyLocation}
+
+// cachedError is an error read back from the probe cache.
+type cachedError struct {
+	message string
+	wrapped error
+}
+
+func (e cachedError) Error() string { return e.message }
+
+func (e cachedError) Unwrap() error { return e.wrapped }
+
+// MarshalJSON encodes the probe for the probe cache, Err included.
+func (p Probe) MarshalJSON() ([]byte, error) {
+	type fields Probe
+	cached := struct {
+		fields
+		Err   string `json:",omitempty"`
+		ErrIs string `json:",omitempty"`
+	}{fields: fields(p)}
+	if p.Err != nil {
+		cached.Err = p.Err.Error()
+		for _, sentinel := range cachedErrors {
+			if errors.Is(p.Err, sentinel) {
+				cached.ErrIs = sentinel.Error()
+				break
+			}
+		}
+	}
+	return json.Marshal(cached)
+}
+
+// UnmarshalJSON decodes a probe written by MarshalJSON.
+func (p *Probe) UnmarshalJSON(data []byte) error {
+	type fields Probe
+	var cached struct {
+		fields
+		Err   string
+		ErrIs string
+	}
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return err
+	}
+	*p = Probe(cached.fields)
+	if cached.Err != "" {
+		restored := cachedError{message: cached.Err}
+		for _, sentinel := range cachedErrors {
+			if sentinel.Error() == cached.ErrIs {
+				restored.wrapped = sentinel
+			}
+		}
+		p.Err = restored
+	}
+	return nil
+}
+
+// Status returns the status of the URL itself, or its failure code.
+func (p Probe) Status() string {
+	if len(p.Hops) == 0 && p.Failure != "" {
+		return p.Failure
+	}
+	return strconv.Itoa(p.StatusCode)
+}
+
+// FinalStatus returns the status reached after the redirects, or the failure
+// code of the request that got no response.
+func (p Probe) FinalStatus() string {
+	if p.Failure != "" {
+		return p.Failure
+	}
+	return strconv.Itoa(p.FinalStatusCode)
+}
+
+// readContent closes the body, keeping it in Content when keep is true.
+func (p *Probe) readContent(keep bool) {
+	if p.Body == nil {
+		return
+	}
+	if keep {
+		content, _ := io.ReadAll(p.Body)
+		p.Content = string(content)
+	}
+	p.Body.Close()
+	p.Body = nil
+}
+
+// result returns the probe as written to the output. Errors the status
+// already tells are left out.
+func (p Probe) result() *result.Probe {
+	r := &result.Probe{
+		URL:         p.URL,
+		Method:      p.Method,
+		Status:      p.Status(),
+		FinalURL:    p.FinalURL,
+		FinalStatus: p.FinalStatus(),
+		Redirects:   p.Redirects(),
+		Hops:        make([]result.Hop, len(p.Hops)),
+		Failure:     p.Failure,
+		Soft404:     p.Soft404,
+		Canonical:   p.Tags.Canonical,
+		Robots:      p.Tags.Directives(),
+		Attempts:    p.Attempts,
+		DurationMs:  p.Duration.Milliseconds(),
+	}
+	for i, hop := range p.Hops {
+		r.Hops[i] = result.Hop{URL: hop.URL, StatusCode: hop.StatusCode, Location: hop.Location, DurationMs: hop.Duration.Milliseconds()}
+	}
+	for _, alternate := range p.Tags.Hreflang {
+		r.Hreflang = append(r.Hreflang, result.Alternate{Lang: alternate.Lang, URL: alternate.URL})
+	}
+	if p.Page != (product.Page{}) {
+		r.Page = &result.Page{Title: p.Page.Title, H1: p.Page.H1, Name: p.Page.Name, Sku: p.Page.Sku, Price: p.Page.Price, Kind: p.Page.Kind}
+	}
+	if p.Err != nil && !errors.Is(p.Err, errStatus) {
+		r.Error = p.Err.Error()
+	}
+	return r
+}
+
+// facts returns what the classification rules know about the probe.
+func (p Probe) facts() rules.Probe {
+	return rules.Probe{
+		Status:      p.Status(),
+		FinalStatus: p.FinalStatus(),
+		Hops:        p.Redirects(),
+		FinalURL:    p.FinalURL,
+		Body:        p.Content,
+		Soft404:     p.Soft404,
+
+		Canonical:          p.Tags.Canonical,
+		CanonicalElsewhere: p.Tags.Canonical != "" && !sameURL(p.Tags.Canonical, p.FinalURL),
+		Robots:             strings.Join(p.Tags.Directives(), ", "),
+		Noindex:            p.Tags.Noindex(),
+		Kind:               p.Page.Kind,
+	}
+}
+
+// Redirects returns the number of redirects followed.
+func (p Probe) Redirects() int {
+	if len(p.Hops) == 0 {
+		return 0
+	}
+	return len(p.Hops) - 1
+}
+
+// Chain formats the hops as "301 https://a -> 200 https://b".
+func (p Probe) Chain() string {
+	parts := make([]string, 0, len(p.Hops))
+	for _, hop := range p.Hops {
+		parts = append(parts, strconv.Itoa(hop.StatusCode)+" "+hop.URL)
+	}
+	return strings.Join(parts, " -> ")
+}
+
+// FetchHttp requests targetURL following redirects by hand, up to the "maxHops"
+// option, so every hop of the chain is recorded. The body of the last response
+// is returned only when it answers 200 or 206, cut at the "maxBodySize" option.
+// Each hop is retried as told by the "retries", "retryBackoff" and
+// "retryMaxBackoff" options. A HEAD request answered 405 or 501 is sent again
+// as GET.
+func FetchHttp(ctx context.Context, targetURL string, method string, opts metadata.Map) (Probe, error) {
+	started := time.Now()
+	probe, err := fetchChain(ctx, targetURL, method, opts)
+	probe.Duration = time.Since(started)
+	probe.Err = err
+	return probe, err
+}
+
+func fetchChain(ctx context.Context, targetURL string, method string, opts metadata.Map) (Probe, error) {
+	if method == "" {
+		method = "GET"
+	}
+
+	probe := Probe{URL: targetURL, FinalURL: targetURL}
+
+	meta := metadata.Map{}
+	for k, v := range opts {
+		meta[k] = v
+	}
+	meta["targetURL"] = targetURL
+	meta["method"] = method
+	meta["followRedirects"] = false
+
+	client, err := inputhttp.New(ctx, meta)
+	if err != nil {
+		return probe, err
+	}
+
+	maxHops := meta.AsInt("maxHops", config.DefaultMaxHops)
+	policy := newRetryPolicy(meta)
+	visited := map[string]bool{}
+	current := targetURL
+
+	for {
+		visited[current] = true
+
+		hopStarted := time.Now()
+		res, attempts, err := fetchHop(ctx, client, method, current, policy)
+		probe.Attempts += attempts
+		if err == nil && method == http.MethodHead && headRejected(res.StatusCode) {
+			// The rest of the chain is requested with GET as well, the
+			// server would reject HEAD again.
+			drain(res)
+			method = http.MethodGet
+			res, attempts, err = fetchHop(ctx, client, method, current, policy)
+			probe.Attempts += attempts
+		}
+		probe.Method = method
+		if err != nil {
+			probe.setFinal(current, 0)
+			probe.Failure = classifyError(err)
+			return probe, err
+		}
+
+		hop := Hop{URL: current, StatusCode: res.StatusCode, Duration: time.Since(hopStarted)}
+		if !isRedirect(res.StatusCode) {
+			probe.Hops = append(probe.Hops, hop)
+			probe.setFinal(current, res.StatusCode)
+			return probe.readBody(ctx, res, int64(meta.AsInt("maxBodySize", 0)))
+		}
+
+		drain(res)
+		next, err := resolveLocation(current, res.Header.Get("Location"))
+		hop.Location = next
+		probe.Hops = append(probe.Hops, hop)
+		probe.setFinal(current, res.StatusCode)
+
+		switch {
+		case err != nil:
+			return probe, fmt.Errorf("could not follow redirect of %q: %w", current, err)
+		case visited[next]:
+			return probe, fmt.Errorf("could not follow redirect of %q to %q: %w", current, next, errRedirectLoop)
+		case probe.Redirects() >= maxHops:
+			return probe, fmt.Errorf("could not follow redirect of %q after %d hops: %w", targetURL, maxHops, errTooManyHops)
+		}
+
+		current = next
+	}
+}
+
+// fetchHop requests target, retrying timeouts, reset connections, 429 and
+// 503. It returns the last response or error and the number of attempts.
+func fetchHop(ctx context.Context, client *inputhttp.HTTP, method string, target string, policy retryPolicy) (*http.Response, int, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, target, nil)
+		if err != nil {
+			return nil, attempt + 1, err
+		}
+		res, err := client.Do(req)
+		if attempt >= policy.retries || !retryable(res, err) || ctx.Err() != nil {
+			return res, attempt + 1, err
+		}
+
+		wait := policy.wait(attempt, res)
+		if res != nil {
+			drain(res)
+		}
+		logger.Debug(ctx, "retrying request", zap.String("targetURL", target), zap.Int("attempt", attempt+1), zap.Duration("wait", wait))
+		if err := sleep(ctx, wait); err != nil {
+			return nil, attempt + 1, err
+		}
+	}
+}
+
+func (p *Probe) setFinal(finalURL string, statusCode int) {
+	if len(p.Hops) > 0 {
+		p.StatusCode = p.Hops[0].StatusCode
+	} else {
+		p.StatusCode = statusCode
+	}
+	p.FinalURL = finalURL
+	p.FinalStatusCode = statusCode
+}
+
+// readBody reads up to maxBody bytes of the body of res, all of it when
+// maxBody is 0, and closes it.
+func (p Probe) readBody(ctx context.Context, res *http.Response, maxBody int64) (Probe, error) {
+	defer drain(res)
+
+	var body io.Reader = res.Body
+	if maxBody > 0 {
+		body = io.LimitReader(res.Body, maxBody)
+	}
+
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusPartialContent:
+		p.Tags.XRobotsTag = res.Header.Values("X-Robots-Tag")
+
+		var buf bytes.Buffer
+		length, err := io.Copy(&buf, body)
+		if res.Request != nil && res.Request.Method == http.MethodHead {
+			// A HEAD answer has no body, only the length it would have.
+			length = res.ContentLength
+		}
+
+		if err == nil && length <= 3 && length > 0 {
+			p.markSoft404(fmt.Sprintf("body of %d bytes", length))
+			return p, fmt.Errorf("404 data, or not enough objects on this response: %w", errStatus)
+		}
+
+		p.Body = io.NopCloser(bytes.NewReader(buf.Bytes()))
+
+		return p, nil
+	default:
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, body); err != nil {
+			logger.Error(ctx, err, "could not read response body")
+		}
+		return p, fmt.Errorf("could not complete fetch: target: [%q] - response: [%q] - statusCode [%d]: %w", p.FinalURL, buf.String(), res.StatusCode, errStatus)
+	}
+}
+
+// markSoft404 turns a page answering 200 into a 404 for reason.
+func (p *Probe) markSoft404(reason string) {
+	p.Soft404 = reason
+	p.FinalStatusCode = http.StatusNotFound
+	if len(p.Hops) == 1 {
+		p.StatusCode = http.StatusNotFound
+	}
+}
+
+// headRejected tells a server answered statusCode because it does not
+// support HEAD requests.
+func headRejected(statusCode int) bool {
+	return statusCode == http.StatusMethodNotAllowed || statusCode == http.StatusNotImplemented
+}
+
+func isRedirect(statusCode int) bool {
+	switch statusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+func resolveLocation(current string, location string) (string, error) {
+	if location == "" {
+		return "", errEmptyLocation
+	}
+	base, err := url.Parse(current)
+	if err != nil {
+		return "", err
+	}
+	ref, err := url.Parse(location)
+	if err != nil {
+		return "", err
+	}
+	return base.ResolveReference(ref).String(), nil
+}
+
+func drain(res *http.Response) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+	res.Body.Close()
+}
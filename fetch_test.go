@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/metadata"
+	"github.com/stretchr/testify/require"
+)
+
+func newRedirectSite(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/middle", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/middle", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/new", http.StatusFound)
+	})
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html>new page</html>")
+	})
+	mux.HandleFunc("/loop-a", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop-b", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/loop-b", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop-a", http.StatusMovedPermanently)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetchHttpRedirectChain(t *testing.T) {
+	site := newRedirectSite(t)
+
+	testCases := []struct {
+		desc string
+
+		path             string
+		opts             metadata.Map
+		errAssertionFunc require.ErrorAssertionFunc
+		validate         func(t *testing.T, probe Probe)
+	}{
+		{
+			desc:             "no redirect",
+			path:             "/new",
+			errAssertionFunc: require.NoError,
+			validate: func(t *testing.T, probe Probe) {
+				require.Equal(t, 200, probe.StatusCode)
+				require.Equal(t, 200, probe.FinalStatusCode)
+				require.Equal(t, 0, probe.Redirects())
+				require.NotNil(t, probe.Body)
+			},
+		},
+		{
+			desc:             "following the whole chain",
+			path:             "/old",
+			errAssertionFunc: require.NoError,
+			validate: func(t *testing.T, probe Probe) {
+				require.Equal(t, 301, probe.StatusCode)
+				require.Equal(t, 200, probe.FinalStatusCode)
+				require.Equal(t, site.URL+"/new", probe.FinalURL)
+				require.Equal(t, 2, probe.Redirects())
+				for i, hop := range probe.Hops {
+					require.Positive(t, hop.Duration)
+					probe.Hops[i].Duration = 0
+				}
+				require.Equal(t, []Hop{
+					{URL: site.URL + "/old", StatusCode: 301, Location: site.URL + "/middle"},
+					{URL: site.URL + "/middle", StatusCode: 302, Location: site.URL + "/new"},
+					{URL: site.URL + "/new", StatusCode: 200},
+				}, probe.Hops)
+				require.Equal(t, fmt.Sprintf("301 %[1]s/old -> 302 %[1]s/middle -> 200 %[1]s/new", site.URL), probe.Chain())
+			},
+		},
+		{
+			desc:             "stopping after max hops",
+			path:             "/old",
+			opts:             metadata.Map{"maxHops": 1},
+			errAssertionFunc: require.Error,
+			validate: func(t *testing.T, probe Probe) {
+				require.Equal(t, 301, probe.StatusCode)
+				require.Equal(t, 302, probe.FinalStatusCode)
+				require.Equal(t, site.URL+"/middle", probe.FinalURL)
+				require.Len(t, probe.Hops, 2)
+			},
+		},
+		{
+			desc:             "detecting loops",
+			path:             "/loop-a",
+			errAssertionFunc: require.Error,
+			validate: func(t *testing.T, probe Probe) {
+				require.Equal(t, 301, probe.StatusCode)
+				require.Len(t, probe.Hops, 2)
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			probe, err := FetchHttp(context.Background(), site.URL+tC.path, "GET", tC.opts)
+			tC.errAssertionFunc(t, err)
+			tC.validate(t, probe)
+		})
+	}
+}
+
+func TestFetchHttpRetries(t *testing.T) {
+	var calls int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/busy", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "<html>back</html>")
+	})
+	mux.HandleFunc("/throttled", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	})
+	site := httptest.NewServer(mux)
+	t.Cleanup(site.Close)
+
+	testCases := []struct {
+		desc string
+
+		path     string
+		opts     metadata.Map
+		validate func(t *testing.T, probe Probe, err error)
+	}{
+		{
+			desc: "succeeding after 503",
+			path: "/busy",
+			opts: metadata.Map{"retries": 2, "retryBackoff": time.Millisecond},
+			validate: func(t *testing.T, probe Probe, err error) {
+				require.NoError(t, err)
+				require.Equal(t, 200, probe.StatusCode)
+				require.Equal(t, 3, probe.Attempts)
+			},
+		},
+		{
+			desc: "giving up on 429",
+			path: "/throttled",
+			opts: metadata.Map{"retries": 1, "retryBackoff": time.Millisecond},
+			validate: func(t *testing.T, probe Probe, err error) {
+				require.Error(t, err)
+				require.Equal(t, "429", probe.Status())
+				require.Equal(t, 2, probe.Attempts)
+			},
+		},
+		{
+			desc: "reporting a timeout",
+			path: "/slow",
+			opts: metadata.Map{"retries": 1, "retryBackoff": time.Millisecond, "timeout": 20 * time.Millisecond},
+			validate: func(t *testing.T, probe Probe, err error) {
+				require.Error(t, err)
+				require.Equal(t, FailureTimeout, probe.Failure)
+				require.Equal(t, FailureTimeout, probe.Status())
+				require.Equal(t, FailureTimeout, probe.FinalStatus())
+				require.Equal(t, 2, probe.Attempts)
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			probe, err := FetchHttp(context.Background(), site.URL+tC.path, "GET", tC.opts)
+			tC.validate(t, probe, err)
+		})
+	}
+}
+
+func TestFetchHttpFailures(t *testing.T) {
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	tlsSite := httptest.NewTLSServer(http.NotFoundHandler())
+	t.Cleanup(tlsSite.Close)
+
+	testCases := []struct {
+		desc string
+
+		target  string
+		failure string
+	}{
+		{desc: "connection refused", target: closed.URL, failure: FailureRefused},
+		{desc: "unknown certificate", target: tlsSite.URL, failure: FailureTLS},
+		{desc: "unknown host", target: "http://cliquefarma.invalid/", failure: FailureDNS},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			probe, err := FetchHttp(context.Background(), tC.target, "GET", metadata.Map{"retries": 2})
+			require.Error(t, err)
+			require.Equal(t, tC.failure, probe.Failure)
+			require.Equal(t, 1, probe.Attempts)
+		})
+	}
+}
+
+func TestFetchHttpHead(t *testing.T) {
+	var gets int32
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			atomic.AddInt32(&gets, 1)
+		}
+		switch r.URL.Path {
+		case "/no-head":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+		case "/redirect":
+			http.Redirect(w, r, "/page", http.StatusMovedPermanently)
+			return
+		case "/tiny":
+			fmt.Fprint(w, "{}")
+			return
+		}
+		fmt.Fprint(w, "<html>"+strings.Repeat("product page ", 1000)+"</html>")
+	}))
+	t.Cleanup(site.Close)
+
+	testCases := []struct {
+		desc string
+
+		path        string
+		method      string
+		finalStatus string
+		attempts    int
+		gets        int32
+	}{
+		{desc: "head answered", path: "/page", method: http.MethodHead, finalStatus: "200", attempts: 1},
+		{desc: "head followed through redirects", path: "/redirect", method: http.MethodHead, finalStatus: "200", attempts: 2},
+		{desc: "head rejected", path: "/no-head", method: http.MethodGet, finalStatus: "200", attempts: 2, gets: 1},
+		{desc: "tiny body told by content length", path: "/tiny", method: http.MethodHead, finalStatus: "404", attempts: 1},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			atomic.StoreInt32(&gets, 0)
+			probe, _ := FetchHttp(context.Background(), site.URL+tC.path, http.MethodHead, metadata.Map{})
+			probe.readContent(true)
+			require.Equal(t, tC.method, probe.Method)
+			require.Equal(t, tC.finalStatus, probe.FinalStatus())
+			require.Equal(t, tC.attempts, probe.Attempts)
+			require.Equal(t, tC.gets, atomic.LoadInt32(&gets))
+			if tC.method == http.MethodHead {
+				require.Empty(t, probe.Content)
+			}
+		})
+	}
+}
+
+func TestFetchHttpMaxBodySize(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html>"+strings.Repeat("product page ", 1000)+"</html>")
+	}))
+	t.Cleanup(site.Close)
+
+	probe, err := FetchHttp(context.Background(), site.URL, "GET", metadata.Map{"maxBodySize": 100})
+	require.NoError(t, err)
+	probe.readContent(true)
+	require.Len(t, probe.Content, 100)
+	require.Equal(t, "200", probe.FinalStatus())
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		desc string
+
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{desc: "seconds", value: "3", want: 3 * time.Second, ok: true},
+		{desc: "http date", value: "Mon, 01 Jan 2024 12:00:05 GMT", want: 5 * time.Second, ok: true},
+		{desc: "date in the past", value: "Mon, 01 Jan 2024 11:00:00 GMT", want: 0, ok: true},
+		{desc: "missing", value: "", ok: false},
+		{desc: "invalid", value: "soon", ok: false},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			got, ok := retryAfter(tC.value, now)
+			require.Equal(t, tC.ok, ok)
+			require.Equal(t, tC.want, got)
+		})
+	}
+}
+
+func TestProbeJSON(t *testing.T) {
+	probe := Probe{
+		URL:             "https://www.cliquefarma.com.br/a",
+		StatusCode:      301,
+		FinalURL:        "https://www.cliquefarma.com.br/a",
+		FinalStatusCode: 301,
+		Hops:            []Hop{{URL: "https://www.cliquefarma.com.br/a", StatusCode: 301, Location: "https://www.cliquefarma.com.br/a", Duration: time.Millisecond}},
+		Err:             fmt.Errorf("could not follow redirect: %w", errRedirectLoop),
+		Attempts:        1,
+	}
+
+	data, err := json.Marshal(probe)
+	require.NoError(t, err)
+	var decoded Probe
+	require.NoError(t, json.Unmarshal(data, &decoded))
+
+	require.ErrorIs(t, decoded.Err, errRedirectLoop)
+	require.EqualError(t, decoded.Err, probe.Err.Error())
+	decoded.Err, probe.Err = nil, nil
+	require.Equal(t, probe, decoded)
+}
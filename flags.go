@@ -0,0 +1,100 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/config"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/result"
+)
+
+const usageHeader = `Analyze URLs to redirect according to SEO rules.
+
+Usage:
+  cliquefarma-analize-redirect-csv [command] [flags]
+
+Commands:
+  analyze   classify every De/Para pair before shipping the redirects (default)
+  scan      find the unsafe slugs of a catalog export and write the rows to analyze
+  validate  check the De/Para pairs as a whole for loops, chains and conflicts
+  slugs     check every New Slug against the one generated from its Old Slug
+  sitemap   check the sitemap lists every Para URL and no De URL
+  verify    check every De URL answers a single 301/308 to its Para URL
+  crawl     find the pages of the site still linking to De URLs
+  export    turn the analysis output into nginx, Apache or _redirects rules
+  migrate   turn the ALTERAR rows into SQL updating the product slugs
+  serve     run analysis jobs of CSVs uploaded over HTTP
+
+Flags override the values read from -config, which override the defaults.
+
+`
+
+// parseFlags builds the run configuration from defaults, an optional
+// YAML file given by -config and the command line flags, in this order.
+func parseFlags(name string, args []string, defaults config.Config) (config.Config, error) {
+	cfg := defaults
+
+	var configPath string
+	fs := newFlagSet(name, &cfg, &configPath)
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	if configPath != "" {
+		cfg = defaults
+		if err := config.LoadFile(configPath, &cfg); err != nil {
+			return cfg, err
+		}
+		// Flags are bound again with the file values as defaults, so only
+		// the flags explicitly given override the file.
+		fs = newFlagSet(name, &cfg, &configPath)
+		if err := fs.Parse(args); err != nil {
+			return cfg, err
+		}
+	}
+
+	if fs.NArg() > 0 {
+		return cfg, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	return cfg, cfg.Validate()
+}
+
+func newFlagSet(name string, cfg *config.Config, configPath *string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usageHeader)
+		fs.PrintDefaults()
+	}
+	fs.SetOutput(os.Stderr)
+
+	fs.StringVar(configPath, "config", *configPath, "path to a YAML config file")
+	fs.StringVar(&cfg.Input, "input", cfg.Input, "CSV file with the products to analyze")
+	fs.StringVar(&cfg.Output, "output", cfg.Output, "CSV file where the analysis is written")
+	fs.StringVar((*string)(&cfg.Format), "format", string(cfg.Format), fmt.Sprintf("output format, one of %q, defaults to the one of the -output extension", result.Formats()))
+	fs.StringVar(&cfg.Report, "report", cfg.Report, "HTML file summarizing the run, e.g. report.html")
+	fs.IntVar(&cfg.Workers, "workers", cfg.Workers, "number of rows analyzed concurrently")
+	fs.IntVar(&cfg.QueueSize, "queue-size", cfg.QueueSize, "number of rows buffered between the reader and the workers")
+	fs.DurationVar(&cfg.Timeout, "timeout", cfg.Timeout, "timeout of each HTTP request")
+	fs.IntVar(&cfg.MaxHops, "max-hops", cfg.MaxHops, "maximum number of redirects followed for each URL")
+	fs.BoolVar(&cfg.HeadFirst, "head-first", cfg.HeadFirst, "send HEAD requests when no page body is read, GET when the server rejects them")
+	fs.IntVar(&cfg.MaxBodySize, "max-body-size", cfg.MaxBodySize, "bytes read from each page, 0 to read it all")
+	fs.Float64Var(&cfg.RequestsPerSecond, "rps", cfg.RequestsPerSecond, "requests per second sent to each host, 0 for no limit")
+	fs.IntVar(&cfg.Burst, "burst", cfg.Burst, "requests a host can get at once before -rps applies")
+	fs.IntVar(&cfg.MaxInFlight, "max-in-flight", cfg.MaxInFlight, "requests running at once on each host, 0 for no limit")
+	fs.BoolVar(&cfg.RobotsCrawlDelay, "robots-crawl-delay", cfg.RobotsCrawlDelay, "slow down to the Crawl-delay of each host robots.txt")
+	fs.IntVar(&cfg.Retries, "retries", cfg.Retries, "retries of timeouts, reset connections, 429 and 503 answers")
+	fs.DurationVar(&cfg.RetryBackoff, "retry-backoff", cfg.RetryBackoff, "first backoff between retries, doubled on each one and jittered")
+	fs.DurationVar(&cfg.RetryMaxBackoff, "retry-max-backoff", cfg.RetryMaxBackoff, "longest wait between retries, Retry-After included")
+	fs.StringVar(&cfg.ProbeCache, "probe-cache", cfg.ProbeCache, "directory keeping the probes between runs, e.g. .probe-cache")
+	fs.DurationVar(&cfg.ProbeCacheTTL, "probe-cache-ttl", cfg.ProbeCacheTTL, "age after which a probe of -probe-cache is fetched again")
+	fs.StringVar(&cfg.BaseHost, "base-host", cfg.BaseHost, "scheme and host replacing the ones of every URL, e.g. https://staging.cliquefarma.com.br")
+	fs.StringVar(&cfg.URLTemplate, "url-template", cfg.URLTemplate, "template building the De and Para URLs missing from a row, e.g. {base}/{departamento}/{slug}")
+	fs.StringVar(&cfg.UserAgent, "user-agent", cfg.UserAgent, "User-Agent header sent on every request")
+	fs.StringVar(&cfg.State, "state", cfg.State, "file keeping the pairs already analyzed, defaults to the output path with a .state suffix")
+	fs.BoolVar(&cfg.Resume, "resume", cfg.Resume, "skip the pairs already analyzed and append to the existing output")
+	fs.StringVar(&cfg.Log, "log", cfg.Log, "log format: prod (JSON), dev or nop")
+
+	return fs
+}
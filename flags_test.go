@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFlags(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte("input: from-file.csv\nworkers: 3\n"), 0o600))
+
+	testCases := []struct {
+		desc string
+
+		args             []string
+		errAssertionFunc require.ErrorAssertionFunc
+		expected         func() config.Config
+	}{
+		{
+			desc:             "no flags, using defaults",
+			errAssertionFunc: require.NoError,
+			expected:         config.Default,
+		},
+		{
+			desc:             "flags only",
+			args:             []string{"-input", "export.csv", "-workers", "5", "-timeout", "2s"},
+			errAssertionFunc: require.NoError,
+			expected: func() config.Config {
+				cfg := config.Default()
+				cfg.Input = "export.csv"
+				cfg.Workers = 5
+				cfg.Timeout = 2 * time.Second
+				return cfg
+			},
+		},
+		{
+			desc:             "flags override config file",
+			args:             []string{"-workers", "8", "-config", configPath},
+			errAssertionFunc: require.NoError,
+			expected: func() config.Config {
+				cfg := config.Default()
+				cfg.Input = "from-file.csv"
+				cfg.Workers = 8
+				return cfg
+			},
+		},
+		{
+			desc:             "invalid value",
+			args:             []string{"-workers", "0"},
+			errAssertionFunc: require.Error,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			cfg, err := parseFlags("analyze", tC.args, config.Default())
+			tC.errAssertionFunc(t, err)
+			if tC.expected != nil {
+				require.Equal(t, tC.expected(), cfg)
+			}
+		})
+	}
+}
+
+func TestRebaseURL(t *testing.T) {
+	got := rebaseURL("https://www.cliquefarma.com.br/sem-categoria/tamanho:g?a=1", "http://localhost:8080")
+	require.Equal(t, "http://localhost:8080/sem-categoria/tamanho:g?a=1", got)
+
+	got = rebaseURL("https://www.cliquefarma.com.br/sem-categoria", "")
+	require.Equal(t, "https://www.cliquefarma.com.br/sem-categoria", got)
+}
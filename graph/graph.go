@@ -0,0 +1,346 @@
+package graph
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Kind is a problem found in the redirect map.
+type Kind string
+
+const (
+	// Loop is a set of redirects leading back to where they started, like
+	// A→B→A, or a URL redirected to itself.
+	Loop Kind = "LOOP"
+	// Conflict is a De URL mapped to different Para URLs.
+	Conflict Kind = "CONFLICT"
+	// Chain is a redirect whose Para is redirected again, to be flattened so
+	// it leads straight to the last URL: A→B, B→C becomes A→C.
+	Chain Kind = "CHAIN"
+	// ToIsFrom is a Para URL that is also the De URL of other rows.
+	ToIsFrom Kind = "PARA_IS_DE"
+)
+
+// Edge is a De→Para pair of a row.
+type Edge struct {
+	Sku  string
+	From string
+	To   string
+}
+
+// Issue is a problem found in the redirect map.
+type Issue struct {
+	Kind Kind
+	// Skus of the rows involved, comma separated.
+	Sku  string
+	From string
+	To   string
+	// Flattened is the URL a chain should redirect to, empty when the chain
+	// ends in a loop or a conflict.
+	Flattened string
+	Detail    string
+}
+
+// Graph is the redirect map made by every De/Para pair. URLs are compared
+// ignoring the case of scheme and host.
+type Graph struct {
+	// names keeps the URL as first written for each key.
+	names   map[string]string
+	targets map[string][]string
+	// sources are the From keys redirected to each key.
+	sources map[string][]string
+	// edges are the rows of each From→To key pair.
+	edges map[[2]string][]Edge
+	froms []string
+}
+
+// New builds the graph of edges. Edges with an empty From or To are left out.
+func New(edges []Edge) *Graph {
+	g := &Graph{
+		names:   map[string]string{},
+		targets: map[string][]string{},
+		sources: map[string][]string{},
+		edges:   map[[2]string][]Edge{},
+	}
+	for _, e := range edges {
+		if e.From == "" || e.To == "" {
+			continue
+		}
+		from, to := g.key(e.From), g.key(e.To)
+		pair := [2]string{from, to}
+		if _, ok := g.targets[from]; !ok {
+			g.froms = append(g.froms, from)
+		}
+		if _, ok := g.edges[pair]; !ok {
+			g.targets[from] = append(g.targets[from], to)
+			g.sources[to] = append(g.sources[to], from)
+		}
+		g.edges[pair] = append(g.edges[pair], e)
+	}
+	sort.Strings(g.froms)
+	return g
+}
+
+// key returns the key of rawURL, keeping its first spelling as its name.
+func (g *Graph) key(rawURL string) string {
+	k := Key(rawURL)
+	if _, ok := g.names[k]; !ok {
+		g.names[k] = rawURL
+	}
+	return k
+}
+
+// Key returns rawURL with lowercase scheme and host and its path escaped
+// the same way, so URLs differing only there, like "tamanho%3Ag" and
+// "tamanho:g", are the same node.
+func Key(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || u.Host == "" {
+		return strings.TrimSpace(rawURL)
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.RawPath = ""
+	return u.String()
+}
+
+// Validate returns the loops, conflicts, chains and Para URLs that are a De
+// elsewhere, in this order.
+func (g *Graph) Validate() []Issue {
+	inLoop := map[string]bool{}
+	var issues []Issue
+
+	for _, component := range g.components() {
+		for _, k := range component {
+			inLoop[k] = true
+		}
+		issues = append(issues, g.loopIssue(component))
+	}
+
+	for _, from := range g.froms {
+		targets := g.targets[from]
+		if len(targets) < 2 {
+			continue
+		}
+		names := make([]string, len(targets))
+		var edges []Edge
+		for i, to := range targets {
+			names[i] = g.names[to]
+			edges = append(edges, g.edges[[2]string{from, to}]...)
+		}
+		issues = append(issues, Issue{
+			Kind:   Conflict,
+			Sku:    skus(edges),
+			From:   g.names[from],
+			Detail: "mapped to " + strings.Join(names, ", "),
+		})
+	}
+
+	for _, from := range g.froms {
+		if inLoop[from] || len(g.targets[from]) > 1 {
+			continue
+		}
+		to := g.targets[from][0]
+		if _, ok := g.targets[to]; !ok {
+			continue
+		}
+		path, final, ok := g.follow(from)
+		issue := Issue{
+			Kind:   Chain,
+			Sku:    skus(g.edges[[2]string{from, to}]),
+			From:   g.names[from],
+			To:     g.names[to],
+			Detail: strings.Join(path, " -> "),
+		}
+		if ok {
+			issue.Flattened = g.names[final]
+		} else {
+			issue.Detail += " (ends in a loop or conflict)"
+		}
+		issues = append(issues, issue)
+	}
+
+	for _, k := range g.froms {
+		if inLoop[k] {
+			// Already told by the loop.
+			continue
+		}
+		var incoming []Edge
+		for _, from := range g.sources[k] {
+			incoming = append(incoming, g.edges[[2]string{from, k}]...)
+		}
+		if len(incoming) == 0 {
+			continue
+		}
+		var outgoing []Edge
+		for _, to := range g.targets[k] {
+			outgoing = append(outgoing, g.edges[[2]string{k, to}]...)
+		}
+		issues = append(issues, Issue{
+			Kind:   ToIsFrom,
+			Sku:    skus(incoming),
+			To:     g.names[k],
+			Detail: "De of sku " + skus(outgoing),
+		})
+	}
+
+	return issues
+}
+
+// Resolve returns the URL reached following the redirects of the map from
+// to, which is to itself when it is not redirected. It returns false
+// when the redirects end in a loop or a conflict.
+func (g *Graph) Resolve(to string) (string, bool) {
+	k := Key(to)
+	if _, ok := g.targets[k]; !ok {
+		return to, true
+	}
+	_, final, ok := g.follow(k)
+	if !ok {
+		return to, false
+	}
+	return g.names[final], true
+}
+
+// follow walks the redirects from the key from. It returns the names of the
+// URLs walked, the key of the last one, and false when the walk stopped at a
+// loop or a conflict.
+func (g *Graph) follow(from string) ([]string, string, bool) {
+	path := []string{g.names[from]}
+	visited := map[string]bool{from: true}
+	current := from
+	for {
+		targets, ok := g.targets[current]
+		if !ok {
+			return path, current, true
+		}
+		if len(targets) > 1 {
+			return path, current, false
+		}
+		current = targets[0]
+		path = append(path, g.names[current])
+		if visited[current] {
+			return path, current, false
+		}
+		visited[current] = true
+	}
+}
+
+// components returns the sets of URLs redirecting to each other, found with
+// Tarjan's algorithm, URLs redirected to themselves included.
+func (g *Graph) components() [][]string {
+	var (
+		index    = map[string]int{}
+		low      = map[string]int{}
+		onStack  = map[string]bool{}
+		stack    []string
+		next     int
+		loops    [][]string
+		connect  func(string)
+		minIndex = func(a, b int) int {
+			if a < b {
+				return a
+			}
+			return b
+		}
+	)
+	connect = func(v string) {
+		index[v], low[v] = next, next
+		next++
+		stack = append(stack, v)
+		onStack[v] = true
+
+		for _, w := range g.targets[v] {
+			if _, seen := index[w]; !seen {
+				connect(w)
+				low[v] = minIndex(low[v], low[w])
+			} else if onStack[w] {
+				low[v] = minIndex(low[v], index[w])
+			}
+		}
+
+		if low[v] != index[v] {
+			return
+		}
+		var component []string
+		for {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[w] = false
+			component = append(component, w)
+			if w == v {
+				break
+			}
+		}
+		if len(component) > 1 || g.edges[[2]string{v, v}] != nil {
+			sort.Strings(component)
+			loops = append(loops, component)
+		}
+	}
+
+	for _, from := range g.froms {
+		if _, seen := index[from]; !seen {
+			connect(from)
+		}
+	}
+	sort.Slice(loops, func(i, j int) bool { return loops[i][0] < loops[j][0] })
+	return loops
+}
+
+// loopIssue describes the loop made by component, walking it from its
+// first URL until a URL repeats.
+func (g *Graph) loopIssue(component []string) Issue {
+	members := map[string]bool{}
+	for _, k := range component {
+		members[k] = true
+	}
+
+	var edges []Edge
+	for _, from := range component {
+		for _, to := range g.targets[from] {
+			if members[to] {
+				edges = append(edges, g.edges[[2]string{from, to}]...)
+			}
+		}
+	}
+
+	start := component[0]
+	path := []string{g.names[start]}
+	visited := map[string]bool{start: true}
+	current := start
+	for {
+		for _, to := range g.targets[current] {
+			if members[to] {
+				current = to
+				break
+			}
+		}
+		path = append(path, g.names[current])
+		if visited[current] {
+			break
+		}
+		visited[current] = true
+	}
+
+	return Issue{
+		Kind:   Loop,
+		Sku:    skus(edges),
+		From:   g.names[start],
+		To:     path[1],
+		Detail: strings.Join(path, " -> "),
+	}
+}
+
+// skus returns the distinct skus of edges, comma separated.
+func skus(edges []Edge) string {
+	seen := map[string]bool{}
+	var list []string
+	for _, e := range edges {
+		if !seen[e.Sku] {
+			seen[e.Sku] = true
+			list = append(list, e.Sku)
+		}
+	}
+	return strings.Join(list, ",")
+}
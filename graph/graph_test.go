@@ -0,0 +1,100 @@
+package graph_test
+
+import (
+	"testing"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/graph"
+	"github.com/stretchr/testify/require"
+)
+
+const base = "https://www.cliquefarma.com.br"
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		desc string
+
+		edges    []graph.Edge
+		expected []graph.Issue
+	}{
+		{
+			desc: "straight redirects",
+			edges: []graph.Edge{
+				{Sku: "1", From: base + "/a", To: base + "/b"},
+				{Sku: "2", From: base + "/c", To: base + "/b"},
+				{Sku: "2", From: base + "/c", To: base + "/b"},
+			},
+		},
+		{
+			desc: "loop",
+			edges: []graph.Edge{
+				{Sku: "1", From: base + "/a", To: base + "/b"},
+				{Sku: "2", From: "HTTPS://WWW.cliquefarma.com.br/b", To: base + "/a"},
+				{Sku: "3", From: base + "/self", To: base + "/self"},
+			},
+			expected: []graph.Issue{
+				{Kind: graph.Loop, Sku: "1,2", From: base + "/a", To: base + "/b", Detail: base + "/a -> " + base + "/b -> " + base + "/a"},
+				{Kind: graph.Loop, Sku: "3", From: base + "/self", To: base + "/self", Detail: base + "/self -> " + base + "/self"},
+			},
+		},
+		{
+			desc: "chain",
+			edges: []graph.Edge{
+				{Sku: "1", From: base + "/a", To: base + "/b"},
+				{Sku: "2", From: base + "/b", To: base + "/c"},
+				{Sku: "3", From: base + "/c", To: base + "/d"},
+			},
+			expected: []graph.Issue{
+				{Kind: graph.Chain, Sku: "1", From: base + "/a", To: base + "/b", Flattened: base + "/d", Detail: base + "/a -> " + base + "/b -> " + base + "/c -> " + base + "/d"},
+				{Kind: graph.Chain, Sku: "2", From: base + "/b", To: base + "/c", Flattened: base + "/d", Detail: base + "/b -> " + base + "/c -> " + base + "/d"},
+				{Kind: graph.ToIsFrom, Sku: "1", To: base + "/b", Detail: "De of sku 2"},
+				{Kind: graph.ToIsFrom, Sku: "2", To: base + "/c", Detail: "De of sku 3"},
+			},
+		},
+		{
+			desc: "conflict",
+			edges: []graph.Edge{
+				{Sku: "1", From: base + "/a", To: base + "/b"},
+				{Sku: "2", From: base + "/a", To: base + "/c"},
+				{Sku: "3", From: base + "/x", To: base + "/a"},
+			},
+			expected: []graph.Issue{
+				{Kind: graph.Conflict, Sku: "1,2", From: base + "/a", Detail: "mapped to " + base + "/b, " + base + "/c"},
+				{Kind: graph.Chain, Sku: "3", From: base + "/x", To: base + "/a", Detail: base + "/x -> " + base + "/a (ends in a loop or conflict)"},
+				{Kind: graph.ToIsFrom, Sku: "3", To: base + "/a", Detail: "De of sku 1,2"},
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			require.Equal(t, tC.expected, graph.New(tC.edges).Validate())
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	g := graph.New([]graph.Edge{
+		{Sku: "1", From: base + "/a", To: base + "/b"},
+		{Sku: "2", From: base + "/b", To: base + "/c"},
+		{Sku: "3", From: base + "/x", To: base + "/y"},
+		{Sku: "4", From: base + "/y", To: base + "/x"},
+	})
+
+	testCases := []struct {
+		desc string
+
+		to       string
+		expected string
+		ok       bool
+	}{
+		{desc: "not redirected", to: base + "/c", expected: base + "/c", ok: true},
+		{desc: "redirected again", to: base + "/b", expected: base + "/c", ok: true},
+		{desc: "loop", to: base + "/y", expected: base + "/y", ok: false},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			got, ok := g.Resolve(tC.to)
+			require.Equal(t, tC.ok, ok)
+			require.Equal(t, tC.expected, got)
+		})
+	}
+}
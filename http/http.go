@@ -34,10 +34,28 @@ func New(ctx context.Context, meta metadata.Map) (*HTTP, error) {
 	if method == "" {
 		return nil, errors.New("could not create source with empty http method")
 	}
-	client, err := httpclient.New(targetURL)
+	// A transport given in the metadata is shared between clients, keeping
+	// connections and limits across requests.
+	var client *httpclient.HTTPClient
+	var err error
+	if transport, ok := meta["transport"].(http.RoundTripper); ok {
+		client, err = httpclient.NewWithTransport(targetURL, transport)
+	} else {
+		client, err = httpclient.New(targetURL)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("could not create httpclient with this target url: %w", err)
 	}
+	if timeout := meta.AsDuration("timeout", 0); timeout > 0 {
+		client.Timeout = timeout
+	}
+	// With followRedirects disabled the 3xx response itself is returned,
+	// letting the caller inspect every hop.
+	if !meta.AsBool("followRedirects", true) {
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
 
 	hdrs := map[string]string{
 		"User-Agent": "cliquefarmabot v1.0.0",
@@ -9,6 +9,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -139,3 +140,40 @@ func TestHTTPInput(t *testing.T) {
 		})
 	}
 }
+
+func TestHTTPInputTimeout(t *testing.T) {
+	client, err := inputhttp.New(context.Background(), metadata.Map{
+		"targetURL": "https://www.cliquefarma.com.br",
+		"method":    "GET",
+		"timeout":   "3s",
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3*time.Second, client.Client.Timeout)
+}
+
+func TestHTTPInputNotFollowingRedirects(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+			return
+		}
+		fmt.Fprint(w, "OK")
+	}))
+	defer testServer.Close()
+
+	client, err := inputhttp.New(context.Background(), metadata.Map{
+		"targetURL":       testServer.URL,
+		"method":          "GET",
+		"followRedirects": false,
+	})
+	require.NoError(t, err)
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+"/old", nil)
+	require.NoError(t, err)
+	res, err := client.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	require.Equal(t, http.StatusMovedPermanently, res.StatusCode)
+	require.Equal(t, "/new", res.Header.Get("Location"))
+}
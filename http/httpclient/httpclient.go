@@ -41,13 +41,16 @@ func NewWithTransport(baseURL string, transport http.RoundTripper) (*HTTPClient,
 		if value == "" {
 			continue
 		}
-		switch key {
-		case timeoutQueryKey:
+		httpTransport, isHTTPTransport := httpClient.Transport.(*http.Transport)
+		switch {
+		case key == timeoutQueryKey:
 			httpClient.Timeout, err = time.ParseDuration(value)
-		case maxIdleConnsQueryKey:
-			httpClient.Transport.(*http.Transport).MaxIdleConns, err = strconv.Atoi(value)
-		case maxIdleConnsPerHostQueryKey:
-			httpClient.Transport.(*http.Transport).MaxIdleConnsPerHost, err = strconv.Atoi(value)
+		case !isHTTPTransport:
+			// Wrapping transports keep their own connection settings.
+		case key == maxIdleConnsQueryKey:
+			httpTransport.MaxIdleConns, err = strconv.Atoi(value)
+		case key == maxIdleConnsPerHostQueryKey:
+			httpTransport.MaxIdleConnsPerHost, err = strconv.Atoi(value)
 		}
 		if err != nil {
 			return nil, err
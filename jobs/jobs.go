@@ -0,0 +1,247 @@
+package jobs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// State is the stage a job is in.
+type State string
+
+const (
+	// Queued jobs wait for the jobs before them to finish.
+	Queued State = "queued"
+	// Running jobs are being analyzed. A job still running when the server
+	// stopped is resumed on the next start.
+	Running State = "running"
+	// Done jobs analyzed every row of their input.
+	Done State = "done"
+	// Failed jobs stopped on an error, kept in Job.Error.
+	Failed State = "failed"
+)
+
+const (
+	// InputFile is the name of the uploaded CSV in the job directory.
+	InputFile = "input.csv"
+	jobFile   = "job.json"
+)
+
+// ErrNotFound is returned for an unknown job id.
+var ErrNotFound = errors.New("jobs: job not found")
+
+// Job is an analysis of an uploaded CSV and its progress.
+type Job struct {
+	ID         string         `json:"id"`
+	State      State          `json:"state"`
+	Filename   string         `json:"filename"`
+	TotalRows  int            `json:"totalRows"`
+	Rows       int            `json:"rows"`
+	Pairs      int            `json:"pairs"`
+	ByStatus   map[string]int `json:"byStatus,omitempty"`
+	Errors     int            `json:"errors"`
+	Error      string         `json:"error,omitempty"`
+	CreatedAt  time.Time      `json:"createdAt"`
+	StartedAt  *time.Time     `json:"startedAt,omitempty"`
+	FinishedAt *time.Time     `json:"finishedAt,omitempty"`
+}
+
+// Store keeps every job in a directory of its own under dir, holding the
+// uploaded input, the job.json state and the files written by the analysis.
+type Store struct {
+	mu   sync.Mutex
+	dir  string
+	jobs map[string]*Job
+}
+
+// Open loads the jobs kept in dir, creating it when missing.
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("jobs: could not create directory: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("jobs: could not read directory: %w", err)
+	}
+
+	s := &Store{dir: dir, jobs: map[string]*Job{}}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name(), jobFile))
+		if errors.Is(err, os.ErrNotExist) {
+			// An upload interrupted before its job was saved.
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("jobs: could not read job %s: %w", entry.Name(), err)
+		}
+		var job Job
+		if err := json.Unmarshal(data, &job); err != nil {
+			return nil, fmt.Errorf("jobs: could not decode job %s: %w", entry.Name(), err)
+		}
+		s.jobs[job.ID] = &job
+	}
+	return s, nil
+}
+
+// Create saves input as a new queued job.
+func (s *Store) Create(input io.Reader, filename string) (Job, error) {
+	id, err := newID()
+	if err != nil {
+		return Job{}, err
+	}
+	if err := os.Mkdir(filepath.Join(s.dir, id), 0o755); err != nil {
+		return Job{}, fmt.Errorf("jobs: could not create job directory: %w", err)
+	}
+
+	file, err := os.Create(s.Path(id, InputFile))
+	if err != nil {
+		return Job{}, fmt.Errorf("jobs: could not create input file: %w", err)
+	}
+	_, err = io.Copy(file, input)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.RemoveAll(filepath.Join(s.dir, id))
+		return Job{}, fmt.Errorf("jobs: could not write input file: %w", err)
+	}
+
+	job := &Job{ID: id, State: Queued, Filename: filename, CreatedAt: time.Now().UTC()}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.save(job); err != nil {
+		os.RemoveAll(filepath.Join(s.dir, id))
+		return Job{}, err
+	}
+	s.jobs[id] = job
+	return *job, nil
+}
+
+// Get returns the job with id.
+func (s *Store) Get(id string) (Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	return copyJob(job), nil
+}
+
+// List returns every job, oldest first.
+func (s *Store) List() []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		list = append(list, copyJob(job))
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.Before(list[j].CreatedAt)
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// Pending returns the jobs left to analyze, the ones interrupted while
+// running first, then the queued ones by age.
+func (s *Store) Pending() []Job {
+	var pending []Job
+	for _, job := range s.List() {
+		if job.State == Running {
+			pending = append(pending, job)
+		}
+	}
+	for _, job := range s.List() {
+		if job.State == Queued {
+			pending = append(pending, job)
+		}
+	}
+	return pending
+}
+
+// Update changes the job with id through update and saves it.
+func (s *Store) Update(id string, update func(*Job)) (Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	updated := copyJob(job)
+	update(&updated)
+	if err := s.save(&updated); err != nil {
+		return Job{}, err
+	}
+	s.jobs[id] = &updated
+	return copyJob(&updated), nil
+}
+
+// Delete removes the job with id and its files.
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.jobs, id)
+	if err := os.RemoveAll(filepath.Join(s.dir, id)); err != nil {
+		return fmt.Errorf("jobs: could not delete job %s: %w", id, err)
+	}
+	return nil
+}
+
+// Path returns the path of the file name in the directory of the job id.
+func (s *Store) Path(id string, name string) string {
+	return filepath.Join(s.dir, id, name)
+}
+
+// save writes job through a temporary file, so a crash never leaves a
+// job.json cut in half.
+func (s *Store) save(job *Job) error {
+	data, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		return fmt.Errorf("jobs: could not encode job %s: %w", job.ID, err)
+	}
+	path := s.Path(job.ID, jobFile)
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		return fmt.Errorf("jobs: could not save job %s: %w", job.ID, err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("jobs: could not save job %s: %w", job.ID, err)
+	}
+	return nil
+}
+
+func copyJob(job *Job) Job {
+	c := *job
+	if job.ByStatus != nil {
+		c.ByStatus = make(map[string]int, len(job.ByStatus))
+		for status, count := range job.ByStatus {
+			c.ByStatus[status] = count
+		}
+	}
+	return c
+}
+
+// newID returns a unique id sorting by creation time.
+func newID() (string, error) {
+	random := make([]byte, 4)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("jobs: could not generate id: %w", err)
+	}
+	return time.Now().UTC().Format("20060102T150405") + "-" + hex.EncodeToString(random), nil
+}
@@ -0,0 +1,53 @@
+package jobs_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/jobs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := jobs.Open(dir)
+	require.NoError(t, err)
+
+	first, err := store.Create(strings.NewReader("Sku,Url1De,Url1Para\n"), "first.csv")
+	require.NoError(t, err)
+	require.Equal(t, jobs.Queued, first.State)
+	input, err := os.ReadFile(store.Path(first.ID, jobs.InputFile))
+	require.NoError(t, err)
+	require.Equal(t, "Sku,Url1De,Url1Para\n", string(input))
+
+	second, err := store.Create(strings.NewReader(""), "second.csv")
+	require.NoError(t, err)
+	_, err = store.Update(second.ID, func(job *jobs.Job) {
+		job.State = jobs.Running
+		job.ByStatus = map[string]int{"REDIRECIONAR": 2}
+	})
+	require.NoError(t, err)
+
+	// Jobs survive reopening the store, as after a restart.
+	store, err = jobs.Open(dir)
+	require.NoError(t, err)
+	require.Len(t, store.List(), 2)
+	got, err := store.Get(second.ID)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"REDIRECIONAR": 2}, got.ByStatus)
+
+	pending := store.Pending()
+	require.Len(t, pending, 2)
+	require.Equal(t, second.ID, pending[0].ID, "running jobs are resumed first")
+	require.Equal(t, first.ID, pending[1].ID)
+
+	require.NoError(t, store.Delete(first.ID))
+	_, err = store.Get(first.ID)
+	require.ErrorIs(t, err, jobs.ErrNotFound)
+	_, err = os.Stat(store.Path(first.ID, jobs.InputFile))
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	_, err = store.Update("unknown", func(*jobs.Job) {})
+	require.ErrorIs(t, err, jobs.ErrNotFound)
+}
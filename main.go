@@ -1,38 +1,189 @@
 package main
 
 import (
-	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
+	"go.uber.org/zap"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/cache"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/checkpoint"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/columns"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/config"
 	"github.com/castmetal/cliquefarma-analize-redirect-csv/logger"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/metadata"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/product"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/ratelimit"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/report"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/result"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/rules"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/seo"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/soft404"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/urltemplate"
+)
+
+// Mode selects what RowReader does with every De/Para pair.
+type Mode string
 
-	inputhttp "github.com/castmetal/cliquefarma-analize-redirect-csv/http"
+const (
+	// ModeAnalyze classifies pairs before the redirects are shipped.
+	ModeAnalyze Mode = "analyze"
+	// ModeVerify checks the redirects after they are deployed.
+	ModeVerify Mode = "verify"
 )
 
 type RowReader struct {
-	chRow     chan []string
-	csvwriter *csv.Writer
-	mu        sync.Mutex
-	ctx       context.Context
+	chRow   chan []string
+	writer  result.Writer
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	ctx     context.Context
+	mode    Mode
+	layout  *columns.Layout
+	state   *checkpoint.Store
+	rules   *rules.Engine
+	soft404 *soft404.Detector
+	probes  *cache.Cache[Probe]
+	// urls builds the De and Para URLs missing from a row, when set.
+	urls *urltemplate.Template
+	// probeKey prefixes the cache keys of the probes, see probeKeyPrefix.
+	probeKey string
+	// method is the one of the probes, HEAD when their body is not read.
+	method   string
+	baseHost string
+	httpMeta metadata.Map
+	summary  Summary
+}
+
+// Summary counts what happened during a run.
+type Summary struct {
+	Rows     int
+	Pairs    int
+	ByStatus map[string]int
+	Errors   int
+	// Skipped counts the pairs already analyzed by the run being resumed.
+	Skipped int
+	// Reused counts the probes of URLs already fetched, by this run or, with
+	// the disk cache, by a previous one.
+	Reused int
+}
+
+func NewRowReader(ctx context.Context, writer result.Writer, layout *columns.Layout, state *checkpoint.Store, engine *rules.Engine, detector *soft404.Detector, probes *cache.Cache[Probe], urls *urltemplate.Template, cfg config.Config, mode Mode) *RowReader {
+	method := probeMethod(cfg, mode == ModeAnalyze && (engine.NeedsBody() || engine.NeedsPage() || detector.NeedsBody()))
+	return &RowReader{
+		chRow:    make(chan []string, cfg.QueueSize),
+		writer:   writer,
+		mu:       sync.Mutex{},
+		ctx:      ctx,
+		mode:     mode,
+		layout:   layout,
+		state:    state,
+		rules:    engine,
+		soft404:  detector,
+		probes:   probes,
+		urls:     urls,
+		probeKey: probeKeyPrefix(cfg, method, engine.NeedsBody(), engine.NeedsPage()),
+		method:   method,
+		summary:  Summary{ByStatus: map[string]int{}},
+		baseHost: cfg.BaseHost,
+		httpMeta: httpOptions(cfg),
+	}
+}
+
+// httpOptions returns the FetchHttp options of cfg, sharing a single
+// transport.
+func httpOptions(cfg config.Config) metadata.Map {
+	return metadata.Map{
+		"transport":       newTransport(cfg),
+		"timeout":         cfg.Timeout,
+		"maxHops":         cfg.MaxHops,
+		"maxBodySize":     cfg.MaxBodySize,
+		"retries":         cfg.Retries,
+		"retryBackoff":    cfg.RetryBackoff,
+		"retryMaxBackoff": cfg.RetryMaxBackoff,
+		"headers": map[string]interface{}{
+			"User-Agent": cfg.UserAgent,
+		},
+	}
+}
+
+// newTransport returns the transport shared by every request of a run, so
+// connections are reused and the politeness limits hold across workers.
+func newTransport(cfg config.Config) http.RoundTripper {
+	return ratelimit.NewTransport(http.DefaultTransport.(*http.Transport).Clone(), ratelimit.Config{
+		RequestsPerSecond: cfg.RequestsPerSecond,
+		Burst:             cfg.Burst,
+		MaxInFlight:       cfg.MaxInFlight,
+		RobotsCrawlDelay:  cfg.RobotsCrawlDelay,
+		UserAgent:         cfg.UserAgent,
+	})
+}
+
+// Start launches the given number of consumeRow workers.
+func (r *RowReader) Start(workers int) {
+	for i := 0; i < workers; i++ {
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			r.consumeRow()
+		}()
+	}
+}
+
+// Send queues a row to be analyzed. It returns false when the context
+// was canceled before the row could be queued.
+func (r *RowReader) Send(row []string) bool {
+	select {
+	case <-r.ctx.Done():
+		return false
+	case r.chRow <- row:
+		return true
+	}
+}
+
+// Close stops accepting rows and waits until every queued row was analyzed
+// and written. It returns the run summary.
+func (r *RowReader) Close() (Summary, error) {
+	close(r.chRow)
+	r.wg.Wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	hits, _ := r.probes.Stats()
+	r.summary.Reused = int(hits)
+	return r.summary, r.writer.Close()
 }
 
-func NewRowReader(ctx context.Context, csvwriter *csv.Writer) RowReader {
-	return RowReader{
-		chRow:     make(chan []string, 100),
-		csvwriter: csvwriter,
-		mu:        sync.Mutex{},
-		ctx:       ctx,
+// Progress returns what was analyzed so far.
+func (r *RowReader) Progress() Summary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	progress := r.summary
+	progress.ByStatus = make(map[string]int, len(r.summary.ByStatus))
+	for status, count := range r.summary.ByStatus {
+		progress.ByStatus[status] = count
 	}
+	return progress
 }
 
 func (r *RowReader) consumeRow() {
@@ -45,30 +196,27 @@ func (r *RowReader) consumeRow() {
 				return
 			}
 
-			var wg sync.WaitGroup
+			r.mu.Lock()
+			r.summary.Rows++
+			r.mu.Unlock()
 
-			if row[8] != "" && row[11] != "" {
-				wg.Add(1)
-				go func() {
-					r.analyzeStatusAndWriteResponse(row[8], row[11], row)
-					wg.Done()
-				}()
+			record := r.layout.Record(row)
+			if r.urls != nil {
+				record = r.urls.Fill(r.baseHost, record)
 			}
 
-			if row[9] != "" && row[12] != "" {
-				wg.Add(1)
-				go func() {
-					r.analyzeStatusAndWriteResponse(row[9], row[12], row)
-					wg.Done()
-				}()
-			}
+			var wg sync.WaitGroup
+
+			for _, pair := range record.Pairs {
+				if pair.From == "" || pair.To == "" {
+					continue
+				}
 
-			if row[10] != "" && row[13] != "" {
 				wg.Add(1)
-				go func() {
-					r.analyzeStatusAndWriteResponse(row[10], row[13], row)
+				go func(pair columns.URLPair) {
+					r.handlePair(pair, record)
 					wg.Done()
-				}()
+				}(pair)
 			}
 
 			wg.Wait()
@@ -76,168 +224,551 @@ func (r *RowReader) consumeRow() {
 	}
 }
 
-func (r *RowReader) analyzeStatusAndWriteResponse(from string, to string, row []string) {
-	var status string
-	statusDe, statusPara := r.verifyUrls(from, to)
+func (r *RowReader) handlePair(pair columns.URLPair, record columns.Record) {
+	pair.From = rebaseURL(pair.From, r.baseHost)
+	pair.To = rebaseURL(pair.To, r.baseHost)
 
-	if statusPara == 200 {
-		status = "ANALISAR"
-	} else if statusDe == 200 && statusPara != 200 {
-		status = "REDIRECIONAR"
-	} else {
-		status = "ALTERAR"
+	if r.state.Done(checkpoint.Key{Sku: record.Sku, From: pair.From, To: pair.To}) {
+		r.mu.Lock()
+		r.summary.Skipped++
+		r.mu.Unlock()
+		return
+	}
+
+	switch r.mode {
+	case ModeVerify:
+		r.verifyRedirectAndWriteResponse(pair, record)
+	default:
+		r.analyzeStatusAndWriteResponse(pair, record)
+	}
+}
+
+func (r *RowReader) analyzeStatusAndWriteResponse(pair columns.URLPair, record columns.Record) {
+	probeDe, probePara := r.verifyUrls(pair.From, pair.To)
+
+	facts := rules.Facts{
+		From:          probeDe.facts(),
+		To:            probePara.facts(),
+		FromReachesTo: sameURL(probeDe.FinalURL, pair.To),
+	}
+	if probeDe.FinalStatusCode == http.StatusOK && probePara.FinalStatusCode == http.StatusOK {
+		facts.Similarity, facts.Compared = product.Similarity(probeDe.Page, probePara.Page)
 	}
+	status, explanation := r.rules.Classify(facts)
 
-	strStatusDe := strconv.Itoa(statusDe)
-	strStatusPara := strconv.Itoa(statusPara)
+	res := r.newResult(pair, record, status, explanation, probeDe.result(), probePara.result())
+	if facts.Compared {
+		res.Similarity = &facts.Similarity
+	}
+	r.writeResponse(checkpoint.Key{Sku: record.Sku, From: pair.From, To: pair.To}, res)
+}
 
+// newResult returns the result of a pair, as written to the output.
+func (r *RowReader) newResult(pair columns.URLPair, record columns.Record, status string, explanation string, fromProbe *result.Probe, toProbe *result.Probe) result.Result {
+	return result.Result{
+		Sku:          record.Sku,
+		From:         pair.From,
+		To:           pair.To,
+		FromDerived:  pair.FromDerived,
+		ToDerived:    pair.ToDerived,
+		Status:       status,
+		Explanation:  explanation,
+		OldSlug:      record.OldSlug,
+		NewSlug:      record.NewSlug,
+		Departamento: record.Departamento,
+		Categoria:    record.Categoria,
+		FromProbe:    fromProbe,
+		ToProbe:      toProbe,
+		CheckedAt:    time.Now().UTC(),
+	}
+}
+
+// writeResponse writes the result of a pair, counts it in the summary under
+// its status and marks the pair as analyzed in the state file.
+func (r *RowReader) writeResponse(pair checkpoint.Key, res result.Result) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	rowWritter := []string{
-		row[0], from, to, status, strStatusDe, strStatusPara,
+	// Probes of a canceled run fail because of the cancellation, not because
+	// of the URLs, so they are left to be analyzed when the run is resumed.
+	if r.ctx.Err() != nil {
+		return
+	}
+
+	if err := r.writer.Write(res); err != nil {
+		r.summary.Errors++
+		logger.Error(r.ctx, err, "could not write analysis result")
+		return
 	}
-	_ = r.csvwriter.Write(rowWritter)
+	r.summary.Pairs++
+	r.summary.ByStatus[res.Status]++
 
-	r.csvwriter.Flush()
+	if err := r.state.Mark(pair); err != nil {
+		logger.Error(r.ctx, err, "could not checkpoint analyzed pair")
+	}
 }
 
-func (r *RowReader) verifyUrls(from string, to string) (int, int) {
-	var status1 int
-	var status2 int
+func (r *RowReader) verifyUrls(from string, to string) (Probe, Probe) {
+	var probe1 Probe
+	var probe2 Probe
 	var wg sync.WaitGroup
 
 	wg.Add(1)
-	go func(requestStatus *int) {
-		data, status, _ := FetchHttp(r.ctx, from, "GET")
-
-		if data != nil {
-			data.Close()
-		}
-
-		*requestStatus = status
+	go func(requestProbe *Probe) {
+		*requestProbe = r.probe(from)
 		wg.Done()
-	}(&status1)
+	}(&probe1)
 
 	wg.Add(1)
-	go func(requestStatus *int) {
-		data, status, _ := FetchHttp(r.ctx, to, "GET")
+	go func(requestProbe *Probe) {
+		*requestProbe = r.probe(to)
+		wg.Done()
+	}(&probe2)
 
-		if data != nil {
-			data.Close()
+	wg.Wait()
+
+	return probe1, probe2
+}
+
+// probe fetches and inspects targetURL once per run, however many rows hold
+// it. Probes that got a response are also kept in the disk cache, when set.
+func (r *RowReader) probe(targetURL string) Probe {
+	probe, err := r.probes.Get(r.probeKey+targetURL, func() (Probe, bool) {
+		probe, _ := FetchHttp(r.ctx, targetURL, r.method, r.httpMeta)
+		r.inspect(&probe)
+		if !r.rules.NeedsBody() {
+			// Only the rules read the body once inspected, the cache
+			// would keep it for nothing.
+			probe.Content = ""
 		}
+		return probe, probe.Failure == "" && r.ctx.Err() == nil
+	})
+	if err != nil {
+		logger.Warn(r.ctx, "probe cache failed", zap.String("targetURL", targetURL), zap.Error(err))
+	}
+	return probe
+}
 
-		*requestStatus = status
-		wg.Done()
-	}(&status2)
+// probeKeyPrefix returns the prefix of the probe cache keys. It holds the
+// settings changing what a probe ends up as, so a disk cache left by a run
+// with other settings is not reused.
+func probeKeyPrefix(cfg config.Config, method string, needsBody bool, needsPage bool) string {
+	soft404Config, _ := json.Marshal(cfg.Soft404)
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d|%d|%s|%t|%t|%s", cfg.MaxHops, cfg.MaxBodySize, cfg.UserAgent, needsBody, needsPage, soft404Config)))
+	return hex.EncodeToString(sum[:8]) + " " + method + " "
+}
 
-	wg.Wait()
+// probeMethod returns HEAD when HEAD first is on and the body of the probes
+// is not read, GET otherwise.
+func probeMethod(cfg config.Config, needsBody bool) string {
+	if cfg.HeadFirst && !needsBody {
+		return http.MethodHead
+	}
+	return http.MethodGet
+}
+
+// inspect reads the body of probe when the classification needs it, parses
+// its indexing hints and product, and turns it into a 404 when it is a soft
+// 404 page.
+func (r *RowReader) inspect(probe *Probe) {
+	probe.readContent(r.rules.NeedsBody() || r.rules.NeedsPage() || r.soft404.NeedsBody())
+
+	if probe.FinalStatusCode != http.StatusOK {
+		return
+	}
+	tags := seo.Parse(probe.Content, probe.FinalURL)
+	tags.XRobotsTag = probe.Tags.XRobotsTag
+	probe.Tags = tags
+	probe.Page = product.Extract(probe.Content)
+
+	reason := r.soft404.Detect(soft404.Page{URL: probe.URL, FinalURL: probe.FinalURL, Body: probe.Content})
+	if reason != "" {
+		probe.markSoft404(reason)
+	}
+}
+
+// loadMissingFingerprint fetches the URL known not to exist, so pages alike
+// to what it answers are taken as soft 404s.
+func (r *RowReader) loadMissingFingerprint(missingURL string) {
+	probe, err := FetchHttp(r.ctx, rebaseURL(missingURL, r.baseHost), "GET", r.httpMeta)
+	probe.readContent(true)
+	if probe.FinalStatusCode != http.StatusOK {
+		// A real 404 leaves nothing to compare to.
+		logger.Warn(r.ctx, "known missing url does not answer 200, similarity check disabled",
+			zap.String("targetURL", missingURL), zap.String("status", probe.FinalStatus()), zap.NamedError("fetchError", err))
+		return
+	}
+	r.soft404.SetFingerprint(probe.Content)
+}
+
+// rebaseURL swaps the scheme and host of rawURL by the ones of baseHost,
+// keeping path and query. It allows running the same export against
+// another environment, like staging.
+func rebaseURL(rawURL string, baseHost string) string {
+	if baseHost == "" {
+		return rawURL
+	}
+	base, err := url.Parse(baseHost)
+	if err != nil {
+		return rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	u.Scheme = base.Scheme
+	u.Host = base.Host
+	return u.String()
+}
 
-	return status1, status2
+// commands maps every command to the function running it with its arguments.
+var commands = map[string]func(ctx context.Context, args []string) error{
+	string(ModeAnalyze): func(ctx context.Context, args []string) error {
+		return runMode(ctx, ModeAnalyze, args)
+	},
+	string(ModeVerify): func(ctx context.Context, args []string) error {
+		return runMode(ctx, ModeVerify, args)
+	},
+	"crawl":    runCrawl,
+	"export":   runExport,
+	"migrate":  runMigrate,
+	"scan":     runScan,
+	"serve":    runServe,
+	"sitemap":  runSitemap,
+	"slugs":    runSlugs,
+	"validate": runValidate,
 }
 
 func main() {
-	var csvFile *os.File
-	var csvwriter *csv.Writer
+	command, args := string(ModeAnalyze), os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	runCommand, ok := commands[command]
+	if !ok {
+		log.Fatalf("unknown command %q, run with -h to list the commands", command)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	ctx := context.Background()
+	if err := runCommand(ctx, args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		stop()
+		log.Fatal(err)
+	}
+}
+
+// runMode parses the flags of the analyze and verify commands and runs them.
+func runMode(ctx context.Context, mode Mode, args []string) error {
+	defaults := config.Default()
+	if mode == ModeVerify {
+		defaults.Output = config.DefaultVerifyOutput
+	}
 
-	file, err := os.Open("products_with_special_chars.csv")
+	cfg, err := parseFlags(string(mode), args, defaults)
 	if err != nil {
-		return
+		return err
+	}
+
+	if err := logger.Setup(cfg.Log); err != nil {
+		return err
+	}
+	defer logger.Flush()
+
+	return run(ctx, cfg, mode)
+}
+
+func run(ctx context.Context, cfg config.Config, mode Mode) error {
+	_, err := runWith(ctx, cfg, mode, runHooks{})
+	return err
+}
+
+// runHooks let another caller, like the serve command, follow a run.
+type runHooks struct {
+	// writer receives every result, besides the output file.
+	writer result.Writer
+	// started is called with the RowReader once it is analyzing rows.
+	started func(*RowReader)
+}
+
+func runWith(ctx context.Context, cfg config.Config, mode Mode, hooks runHooks) (Summary, error) {
+	started := time.Now()
+
+	engine, err := rules.New(cfg.Rules)
+	if err != nil {
+		return Summary{}, err
+	}
+	detector, err := soft404.New(cfg.Soft404)
+	if err != nil {
+		return Summary{}, err
+	}
+	probes, err := cache.New[Probe](cfg.ProbeCache, cfg.ProbeCacheTTL)
+	if err != nil {
+		return Summary{}, err
+	}
+	urls, err := cfg.URLs()
+	if err != nil {
+		return Summary{}, err
+	}
+
+	file, err := os.Open(cfg.Input)
+	if err != nil {
+		return Summary{}, fmt.Errorf("failed opening input file: %w", err)
 	}
 	defer file.Close()
 
-	csvFile, err = os.Create("output.csv")
+	format, err := result.FormatFor(cfg.Output, cfg.Format)
 	if err != nil {
-		log.Fatalf("failed creating file: %s", err)
+		return Summary{}, err
+	}
+	outputFile, writer, err := openOutput(cfg.Output, format, outputColumns(mode), cfg.Resume)
+	if err != nil {
+		return Summary{}, err
+	}
+	defer outputFile.Close()
+
+	if hooks.writer != nil {
+		writer = result.MultiWriter(writer, hooks.writer)
 	}
 
-	csvwriter = csv.NewWriter(csvFile)
-	empRow := []string{
-		"Sku", "De", "Para", "Status", "De Status", "Para Status",
+	var collector *report.Collector
+	if cfg.Report != "" {
+		collector = report.NewCollector()
+		writer = result.MultiWriter(writer, collector)
 	}
-	_ = csvwriter.Write(empRow)
 
-	csvwriter.Flush()
-	defer csvFile.Close()
+	state, err := checkpoint.Open(cfg.StatePath(), cfg.Resume)
+	if err != nil {
+		return Summary{}, err
+	}
+	defer state.Close()
 
 	reader := csv.NewReader(file)
-	rowReader := NewRowReader(ctx, csvwriter)
+	header, err := reader.Read()
+	if err != nil {
+		return Summary{}, fmt.Errorf("failed reading input header: %w", err)
+	}
+	layout, err := columns.Parse(header, cfg.Columns)
+	if err != nil {
+		return Summary{}, fmt.Errorf("invalid input file %s: %w", cfg.Input, err)
+	}
+	// Rows may be shorter or longer than the header; the layout reads
+	// missing cells as empty.
+	reader.FieldsPerRecord = -1
 
-	for i := 0; i <= 20; i++ {
-		go rowReader.consumeRow()
+	rowReader := NewRowReader(ctx, writer, layout, state, engine, detector, probes, urls, cfg, mode)
+	if mode == ModeAnalyze && cfg.Soft404.MissingURL != "" {
+		rowReader.loadMissingFingerprint(cfg.Soft404.MissingURL)
+	}
+	rowReader.Start(cfg.Workers)
+	if hooks.started != nil {
+		hooks.started(rowReader)
 	}
 
-	i := 0
+	invalid := 0
 	for {
 		record, err := reader.Read()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
+			invalid++
+			logger.Warn(ctx, "skipping invalid csv row", zap.Error(err))
 			continue
 		}
 
-		if i == 0 {
-			i++
-			continue
+		if !rowReader.Send(record) {
+			break
 		}
+	}
 
-		rowReader.chRow <- record
+	summary, err := rowReader.Close()
+	if err != nil {
+		return summary, fmt.Errorf("failed writing output file: %w", err)
+	}
+	if err := outputFile.Sync(); err != nil {
+		return summary, fmt.Errorf("failed writing output file: %w", err)
 	}
 
-	time.Sleep(5 * time.Second)
+	printSummary(os.Stdout, cfg.Output, summary, invalid, time.Since(started))
+
+	if collector != nil {
+		if err := writeReport(cfg.Report, collector, report.Options{Input: cfg.Input}); err != nil {
+			return summary, err
+		}
+		fmt.Printf("report written to %s\n", cfg.Report)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return summary, err
+	}
+	if mode == ModeVerify && summary.ByStatus[verifyFail] > 0 {
+		return summary, fmt.Errorf("%d redirects failed verification, see %s", summary.ByStatus[verifyFail], cfg.Output)
+	}
+	return summary, nil
 }
 
-func FetchHttp(ctx context.Context, url string, method string) (io.ReadCloser, int, error) {
-	if method == "" {
-		method = "GET"
+// openOutput opens the output file and its result writer. When resuming, an
+// existing output is appended to instead, after checking a CSV output has
+// the same header.
+func openOutput(path string, format result.Format, columns []result.Column, resume bool) (*os.File, result.Writer, error) {
+	appending := false
+	if resume {
+		info, err := os.Stat(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return nil, nil, fmt.Errorf("failed reading output to resume: %w", err)
+		default:
+			appending = info.Size() > 0
+		}
+	}
+	if appending && format == result.CSV {
+		existing, err := readHeader(path)
+		switch {
+		case errors.Is(err, io.EOF):
+			appending = false
+		case err != nil:
+			return nil, nil, fmt.Errorf("failed reading output to resume: %w", err)
+		case strings.Join(existing, ",") != strings.Join(result.Header(columns), ","):
+			return nil, nil, fmt.Errorf("can not resume: %s has a different header, was it written by another command?", path)
+		}
+	}
+	if appending && format == result.JSON {
+		return nil, nil, result.ErrNotAppendable
 	}
 
-	meta := map[string]interface{}{
-		"targetURL": url,
-		"method":    method,
+	var (
+		file *os.File
+		err  error
+	)
+	if appending {
+		file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed opening output to resume: %w", err)
+		}
+		if err := checkpoint.EndLine(path, file); err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+	} else {
+		file, err = os.Create(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed creating file: %w", err)
+		}
 	}
 
-	client, err := inputhttp.New(ctx, meta)
+	writer, err := result.NewWriter(file, format, columns, appending)
 	if err != nil {
-		return nil, 0, err
+		file.Close()
+		return nil, nil, fmt.Errorf("failed writing output: %w", err)
 	}
+	return file, writer, nil
+}
 
-	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+func writeReport(path string, collector *report.Collector, opts report.Options) error {
+	file, err := os.Create(path)
 	if err != nil {
-		return nil, 500, err
+		return fmt.Errorf("failed creating report: %w", err)
 	}
-	res, err := client.Do(req)
+	if err := collector.WriteHTML(file, opts); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func readHeader(path string) ([]string, error) {
+	file, err := os.Open(path)
 	if err != nil {
-		return nil, 500, err
+		return nil, err
 	}
+	defer file.Close()
 
-	switch res.StatusCode {
-	case http.StatusOK, http.StatusPartialContent:
-		var buf bytes.Buffer
-		length, err := io.Copy(&buf, res.Body)
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	return reader.Read()
+}
 
-		if err == nil && length <= 3 && length > 0 {
-			res.Body.Close()
-			buf.Reset()
-			return nil, 404, errors.New("404 data, or not enough objects on this response")
+// outputColumns returns the CSV columns written by mode.
+func outputColumns(mode Mode) []result.Column {
+	if mode == ModeVerify {
+		return verifyColumns
+	}
+	return analyzeColumns
+}
+
+// analyzeColumns are the CSV columns written by the analyze command.
+var analyzeColumns = []result.Column{
+	{Name: "Sku", Value: func(r result.Result) string { return r.Sku }},
+	{Name: "De", Value: func(r result.Result) string { return r.From }},
+	{Name: "Para", Value: func(r result.Result) string { return r.To }},
+	{Name: "Status", Value: func(r result.Result) string { return r.Status }},
+	{Name: "De Status", Value: func(r result.Result) string { return r.FromProbe.Status }},
+	{Name: "Para Status", Value: func(r result.Result) string { return r.ToProbe.Status }},
+	{Name: "De Final URL", Value: func(r result.Result) string { return r.FromProbe.FinalURL }},
+	{Name: "De Final Status", Value: func(r result.Result) string { return r.FromProbe.FinalStatus }},
+	{Name: "De Hops", Value: func(r result.Result) string { return strconv.Itoa(r.FromProbe.Redirects) }},
+	{Name: "De Chain", Value: func(r result.Result) string { return r.FromProbe.Chain() }},
+	{Name: "De Soft 404", Value: func(r result.Result) string { return r.FromProbe.Soft404 }},
+	{Name: "Para Final URL", Value: func(r result.Result) string { return r.ToProbe.FinalURL }},
+	{Name: "Para Final Status", Value: func(r result.Result) string { return r.ToProbe.FinalStatus }},
+	{Name: "Para Hops", Value: func(r result.Result) string { return strconv.Itoa(r.ToProbe.Redirects) }},
+	{Name: "Para Chain", Value: func(r result.Result) string { return r.ToProbe.Chain() }},
+	{Name: "Para Soft 404", Value: func(r result.Result) string { return r.ToProbe.Soft404 }},
+	{Name: "Para Canonical", Value: func(r result.Result) string { return r.ToProbe.Canonical }},
+	{Name: "Para Robots", Value: func(r result.Result) string { return strings.Join(r.ToProbe.Robots, ", ") }},
+	{Name: "Similarity", Value: func(r result.Result) string {
+		if r.Similarity == nil {
+			return ""
 		}
+		return strconv.FormatFloat(*r.Similarity, 'f', 2, 64)
+	}},
+	{Name: "Old Slug", Value: func(r result.Result) string { return r.OldSlug }},
+	{Name: "New Slug", Value: func(r result.Result) string { return r.NewSlug }},
+	{Name: "Derived", Value: derivedColumn},
+	{Name: "Explanation", Value: func(r result.Result) string { return r.Explanation }},
+}
+
+// derivedColumn tells which URLs of a result were built by the URL
+// template: "De", "Para" or "De,Para".
+func derivedColumn(r result.Result) string {
+	var derived []string
+	if r.FromDerived {
+		derived = append(derived, "De")
+	}
+	if r.ToDerived {
+		derived = append(derived, "Para")
+	}
+	return strings.Join(derived, ",")
+}
 
-		closer := io.NopCloser(bytes.NewReader(buf.Bytes()))
+func printSummary(w io.Writer, output string, summary Summary, invalid int, elapsed time.Duration) {
+	fmt.Fprintf(w, "analyzed %d rows (%d pairs) in %s, written to %s\n", summary.Rows, summary.Pairs, elapsed.Round(time.Millisecond), output)
 
-		return closer, res.StatusCode, nil
-	default:
-		var buf bytes.Buffer
-		_, err := io.Copy(&buf, res.Body)
-		if err != nil {
-			logger.Error(ctx, err, "could not read response body")
-			res.Body.Close()
-			return nil, res.StatusCode, fmt.Errorf("could not complete fetch: target: [%q] - response: [%q] - statusCode [%d]", url, buf.String(), res.StatusCode)
-		}
+	statuses := make([]string, 0, len(summary.ByStatus))
+	for status := range summary.ByStatus {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	for _, status := range statuses {
+		fmt.Fprintf(w, "  %-12s %d\n", status, summary.ByStatus[status])
+	}
 
-		res.Body.Close()
-		return nil, res.StatusCode, fmt.Errorf("could not complete fetch: target: [%q] - response: [%q] - statusCode [%d]", url, buf.String(), res.StatusCode)
+	if summary.Skipped > 0 {
+		fmt.Fprintf(w, "  skipped %d pairs already analyzed\n", summary.Skipped)
+	}
+	if summary.Reused > 0 {
+		fmt.Fprintf(w, "  reused %d probes of urls already fetched\n", summary.Reused)
+	}
+	if invalid > 0 {
+		fmt.Fprintf(w, "  skipped %d invalid csv rows\n", invalid)
+	}
+	if summary.Errors > 0 {
+		fmt.Fprintf(w, "  failed writing %d pairs\n", summary.Errors)
 	}
 }
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/config"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/result"
+	"github.com/stretchr/testify/require"
+)
+
+const inputHeader = "Sku,Old Slug,New Slug,Departamento,Categoria,Subcategoria1,Subcategoria2,Subcategoria3,Url1De,Url2De,Url3De,Url1Para,Url2Para,Url3Para\n"
+
+// newTestConfig returns a config reading input.csv and writing output.csv
+// in dir, without logs nor rate limits.
+func newTestConfig(dir string) config.Config {
+	cfg := config.Default()
+	cfg.Input = filepath.Join(dir, "input.csv")
+	cfg.Output = filepath.Join(dir, "output.csv")
+	cfg.Log = "nop"
+	cfg.RequestsPerSecond = 0
+	cfg.MaxInFlight = 0
+	return cfg
+}
+
+func newTestSite(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/missing") {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "<html>product page</html>")
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func readOutput(t *testing.T, path string) [][]string {
+	t.Helper()
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	require.NoError(t, err)
+	return records
+}
+
+func TestRunWritesEveryRow(t *testing.T) {
+	site := newTestSite(t)
+	dir := t.TempDir()
+
+	var input strings.Builder
+	input.WriteString(inputHeader)
+	rows := 250
+	for i := 0; i < rows; i++ {
+		fmt.Fprintf(&input, "%d,old,new,dep,,,,,%s/old-%d,,,%s/missing-%d,,\n", i, site.URL, i, site.URL, i)
+	}
+
+	cfg := newTestConfig(dir)
+	cfg.Workers = 4
+	require.NoError(t, os.WriteFile(cfg.Input, []byte(input.String()), 0o600))
+
+	require.NoError(t, run(context.Background(), cfg, ModeAnalyze))
+
+	records := readOutput(t, cfg.Output)
+	require.Len(t, records, rows+1)
+	require.Equal(t, []string{"Sku", "De", "Para", "Status", "De Status", "Para Status"}, records[0][:6])
+	for _, record := range records[1:] {
+		require.Equal(t, "REDIRECIONAR", record[3])
+		require.Equal(t, "De answers 200 and Para 404", record[len(record)-1])
+	}
+}
+
+func TestRunRejectsUnknownLayout(t *testing.T) {
+	dir := t.TempDir()
+
+	cfg := newTestConfig(dir)
+	require.NoError(t, os.WriteFile(cfg.Input, []byte("Codigo Produto,Url1De\n1,https://www.cliquefarma.com.br/a\n"), 0o600))
+
+	err := run(context.Background(), cfg, ModeAnalyze)
+	require.ErrorContains(t, err, "missing required column sku")
+}
+
+func TestRunResume(t *testing.T) {
+	site := newTestSite(t)
+	dir := t.TempDir()
+
+	var input strings.Builder
+	input.WriteString(inputHeader)
+	for i := 0; i < 10; i++ {
+		fmt.Fprintf(&input, "%d,old,new,dep,,,,,%s/old-%d,,,%s/missing-%d,,\n", i, site.URL, i, site.URL, i)
+	}
+
+	cfg := newTestConfig(dir)
+	require.NoError(t, os.WriteFile(cfg.Input, []byte(input.String()), 0o600))
+	require.NoError(t, run(context.Background(), cfg, ModeAnalyze))
+
+	// Simulates a run interrupted after the first 4 pairs.
+	records := readOutput(t, cfg.Output)
+	var partial strings.Builder
+	w := csv.NewWriter(&partial)
+	require.NoError(t, w.WriteAll(records[:5]))
+	require.NoError(t, os.WriteFile(cfg.Output, []byte(partial.String()), 0o600))
+
+	var state strings.Builder
+	w = csv.NewWriter(&state)
+	for _, record := range records[1:5] {
+		require.NoError(t, w.Write([]string{record[0], record[1], record[2]}))
+	}
+	w.Flush()
+	require.NoError(t, os.WriteFile(cfg.StatePath(), []byte(state.String()), 0o600))
+
+	cfg.Resume = true
+	require.NoError(t, run(context.Background(), cfg, ModeAnalyze))
+
+	resumed := readOutput(t, cfg.Output)
+	require.Len(t, resumed, 11)
+	require.Equal(t, records[0], resumed[0])
+	skus := map[string]bool{}
+	for _, record := range resumed[1:] {
+		require.False(t, skus[record[0]], "sku %s written twice", record[0])
+		skus[record[0]] = true
+	}
+}
+
+func TestRunResumeRejectsOtherOutput(t *testing.T) {
+	dir := t.TempDir()
+
+	cfg := newTestConfig(dir)
+	cfg.Resume = true
+	require.NoError(t, os.WriteFile(cfg.Input, []byte(inputHeader), 0o600))
+	require.NoError(t, os.WriteFile(cfg.Output, []byte("Sku,De,Para,Result\n"), 0o600))
+
+	err := run(context.Background(), cfg, ModeAnalyze)
+	require.ErrorContains(t, err, "different header")
+}
+
+func TestRunSoft404(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sem-estoque", "/nao-existe":
+			fmt.Fprint(w, "<html><title>Ops</title><h1>Não encontramos o produto que você procura</h1></html>")
+		case "/home-redirect":
+			http.Redirect(w, r, "/", http.StatusMovedPermanently)
+		default:
+			fmt.Fprint(w, "<html><title>Dipirona</title><h1>Dipirona 500mg 10 comprimidos por R$ 5,99</h1></html>")
+		}
+	}))
+	t.Cleanup(site.Close)
+	dir := t.TempDir()
+
+	input := inputHeader +
+		fmt.Sprintf("1,old,new,,,,,,%[1]s/sem-estoque,,,%[1]s/dipirona,,\n", site.URL) +
+		fmt.Sprintf("2,old,new,,,,,,%[1]s/dipirona,,,%[1]s/home-redirect,,\n", site.URL)
+
+	cfg := newTestConfig(dir)
+	cfg.Soft404.RedirectToHome = true
+	cfg.Soft404.MissingURL = site.URL + "/nao-existe"
+	require.NoError(t, os.WriteFile(cfg.Input, []byte(input), 0o600))
+
+	require.NoError(t, run(context.Background(), cfg, ModeAnalyze))
+
+	records := readOutput(t, cfg.Output)
+	require.Len(t, records, 3)
+	column := map[string]int{}
+	for i, name := range records[0] {
+		column[name] = i
+	}
+	bySku := map[string][]string{}
+	for _, record := range records[1:] {
+		bySku[record[0]] = record
+	}
+
+	require.Equal(t, "100% similar to "+cfg.Soft404.MissingURL, bySku["1"][column["De Soft 404"]])
+	require.Equal(t, "404", bySku["1"][column["De Final Status"]])
+	require.Equal(t, "ANALISAR", bySku["1"][column["Status"]])
+
+	require.Equal(t, "redirected to the home page", bySku["2"][column["Para Soft 404"]])
+	require.Equal(t, "404", bySku["2"][column["Para Final Status"]])
+	require.Equal(t, "REDIRECIONAR", bySku["2"][column["Status"]])
+}
+
+func TestRunProbeCache(t *testing.T) {
+	var requests sync.Map
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count, _ := requests.LoadOrStore(r.URL.Path, new(int32))
+		atomic.AddInt32(count.(*int32), 1)
+		if r.URL.Path == "/sem-categoria" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "<html>product page</html>")
+	}))
+	t.Cleanup(site.Close)
+	dir := t.TempDir()
+
+	input := inputHeader +
+		fmt.Sprintf("1,old,new,,,,,,%[1]s/old-1,,,%[1]s/sem-categoria,,\n", site.URL) +
+		fmt.Sprintf("2,old,new,,,,,,%[1]s/old-2,%[1]s/old-1,,%[1]s/sem-categoria,%[1]s/sem-categoria,\n", site.URL) +
+		fmt.Sprintf("3,old,new,,,,,,%[1]s/old-3,,,%[1]s/sem-categoria,,\n", site.URL)
+
+	cfg := newTestConfig(dir)
+	cfg.ProbeCache = filepath.Join(dir, "probes")
+	require.NoError(t, os.WriteFile(cfg.Input, []byte(input), 0o600))
+
+	require.NoError(t, run(context.Background(), cfg, ModeAnalyze))
+	first := readOutput(t, cfg.Output)
+	require.Len(t, first, 5)
+	requests.Range(func(path, count any) bool {
+		require.Equal(t, int32(1), *count.(*int32), "fetches of %s", path)
+		return true
+	})
+
+	// The next run reads every probe from the disk cache.
+	requests = sync.Map{}
+	require.NoError(t, run(context.Background(), cfg, ModeAnalyze))
+	requests.Range(func(path, _ any) bool {
+		t.Errorf("%s fetched again", path)
+		return true
+	})
+	second := readOutput(t, cfg.Output)
+	require.ElementsMatch(t, first, second)
+}
+
+func TestRunNDJSON(t *testing.T) {
+	site := newTestSite(t)
+	dir := t.TempDir()
+
+	input := inputHeader +
+		fmt.Sprintf("1,old,new,dep,cat,,,,%[1]s/old-1,,,%[1]s/missing-1,,\n", site.URL) +
+		fmt.Sprintf("2,old,new,dep,cat,,,,%[1]s/old-2,,,%[1]s/missing-2,,\n", site.URL)
+
+	cfg := newTestConfig(dir)
+	cfg.Output = filepath.Join(dir, "output.ndjson")
+	cfg.Resume = true
+	require.NoError(t, os.WriteFile(cfg.Input, []byte(input), 0o600))
+
+	require.NoError(t, run(context.Background(), cfg, ModeAnalyze))
+	// Resuming appends nothing, every pair was already analyzed.
+	require.NoError(t, run(context.Background(), cfg, ModeAnalyze))
+
+	data, err := os.ReadFile(cfg.Output)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	require.Len(t, lines, 2)
+
+	var res result.Result
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &res))
+	require.Equal(t, "REDIRECIONAR", res.Status)
+	require.Equal(t, "dep", res.Departamento)
+	require.Equal(t, "200", res.FromProbe.FinalStatus)
+	require.Equal(t, "404", res.ToProbe.FinalStatus)
+	require.Len(t, res.ToProbe.Hops, 1)
+	require.Equal(t, 1, res.ToProbe.Attempts)
+	require.Empty(t, res.ToProbe.Error)
+}
+
+func TestRunJSONRefusesResume(t *testing.T) {
+	site := newTestSite(t)
+	dir := t.TempDir()
+
+	cfg := newTestConfig(dir)
+	cfg.Output = filepath.Join(dir, "output.json")
+	require.NoError(t, os.WriteFile(cfg.Input, []byte(inputHeader+fmt.Sprintf("1,old,new,,,,,,%[1]s/a,,,%[1]s/b,,\n", site.URL)), 0o600))
+	require.NoError(t, run(context.Background(), cfg, ModeAnalyze))
+
+	var results []result.Result
+	data, err := os.ReadFile(cfg.Output)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &results))
+	require.Len(t, results, 1)
+
+	cfg.Resume = true
+	require.ErrorIs(t, run(context.Background(), cfg, ModeAnalyze), result.ErrNotAppendable)
+}
+
+func TestRunReport(t *testing.T) {
+	site := newTestSite(t)
+	dir := t.TempDir()
+
+	cfg := newTestConfig(dir)
+	cfg.Report = filepath.Join(dir, "report.html")
+	require.NoError(t, os.WriteFile(cfg.Input, []byte(inputHeader+fmt.Sprintf("1,old,new,Medicamentos,Dor,,,,%[1]s/old-1,,,%[1]s/missing-1,,\n", site.URL)), 0o600))
+
+	require.NoError(t, run(context.Background(), cfg, ModeAnalyze))
+
+	html, err := os.ReadFile(cfg.Report)
+	require.NoError(t, err)
+	require.Contains(t, string(html), "<td>Medicamentos</td><td>Dor</td>")
+	require.Contains(t, string(html), `"status":"REDIRECIONAR"`)
+}
+
+func TestRunURLTemplate(t *testing.T) {
+	site := newTestSite(t)
+	dir := t.TempDir()
+
+	cfg := newTestConfig(dir)
+	cfg.BaseHost = site.URL
+	cfg.URLTemplate = "{base}/{slug}"
+	require.NoError(t, os.WriteFile(cfg.Input, []byte(inputHeader+
+		"1,old-1,missing-1,,,,,,,,,,,\n"+
+		"2,old-2,missing-2,,,,,,"+site.URL+"/page-2,,,,,\n"+
+		"3,old-3,,,,,,,,,,,,\n",
+	), 0o600))
+
+	require.NoError(t, run(context.Background(), cfg, ModeAnalyze))
+
+	records := readOutput(t, cfg.Output)
+	require.Len(t, records, 3, "row without new slug skipped")
+	derived := len(records[0]) - 2
+	require.Equal(t, "Derived", records[0][derived])
+	bySku := map[string][]string{}
+	for _, record := range records[1:] {
+		bySku[record[0]] = record
+	}
+	require.Equal(t, []string{site.URL + "/old-1", site.URL + "/missing-1", "REDIRECIONAR"}, bySku["1"][1:4])
+	require.Equal(t, "De,Para", bySku["1"][derived])
+	require.Equal(t, []string{site.URL + "/page-2", site.URL + "/missing-2", "REDIRECIONAR"}, bySku["2"][1:4])
+	require.Equal(t, "Para", bySku["2"][derived])
+}
+
+func TestRunParaIndexing(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html>old page</html>")
+	})
+	mux.HandleFunc("/meta-noindex", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><head><meta name="robots" content="noindex"></head></html>`)
+	})
+	mux.HandleFunc("/header-noindex", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+		fmt.Fprint(w, "<html>product page</html>")
+	})
+	mux.HandleFunc("/canonical", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><head><link rel="canonical" href="/other"></head></html>`)
+	})
+	mux.HandleFunc("/self", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><head><link rel="canonical" href="/self"></head></html>`)
+	})
+	site := httptest.NewServer(mux)
+	t.Cleanup(site.Close)
+	dir := t.TempDir()
+
+	cfg := newTestConfig(dir)
+	var input strings.Builder
+	input.WriteString(inputHeader)
+	for i, path := range []string{"/meta-noindex", "/header-noindex", "/canonical", "/self"} {
+		fmt.Fprintf(&input, "%d,old,new,,,,,,%s/old,,,%s%s,,\n", i, site.URL, site.URL, path)
+	}
+	require.NoError(t, os.WriteFile(cfg.Input, []byte(input.String()), 0o600))
+
+	require.NoError(t, run(context.Background(), cfg, ModeAnalyze))
+
+	records := readOutput(t, cfg.Output)
+	require.Len(t, records, 5)
+	bySku := map[string]map[string]string{}
+	for _, record := range records[1:] {
+		row := map[string]string{}
+		for i, name := range records[0] {
+			row[name] = record[i]
+		}
+		bySku[record[0]] = row
+	}
+
+	require.Equal(t, "NOINDEX", bySku["0"]["Status"])
+	require.Equal(t, "noindex", bySku["0"]["Para Robots"])
+	require.Equal(t, "NOINDEX", bySku["1"]["Status"])
+	require.Equal(t, "Para answers 200 but is not to be indexed: noindex, nofollow", bySku["1"]["Explanation"])
+	require.Equal(t, "CANONICAL_DIVERGENTE", bySku["2"]["Status"])
+	require.Equal(t, site.URL+"/other", bySku["2"]["Para Canonical"])
+	require.Equal(t, "ANALISAR", bySku["3"]["Status"])
+}
+
+func TestRunSimilarity(t *testing.T) {
+	productPage := func(name string, sku string) string {
+		return fmt.Sprintf(`<html><head><title>%[1]s</title><script type="application/ld+json">{"@type":"Product","name":%[1]q,"sku":%[2]q}</script></head><body><h1>%[1]s</h1></body></html>`, name, sku)
+	}
+	pages := map[string]string{
+		"/old":      productPage("Dipirona 500mg 10 Comprimidos", "1"),
+		"/same":     productPage("Dipirona Sódica 500mg 10 Comprimidos", "1"),
+		"/other":    productPage("Protetor Solar FPS 50", "2"),
+		"/category": `<html><head><title>Analgésicos</title><script type="application/ld+json">{"@type":"CollectionPage"}</script></head></html>`,
+	}
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, pages[r.URL.Path])
+	}))
+	t.Cleanup(site.Close)
+	dir := t.TempDir()
+
+	cfg := newTestConfig(dir)
+	var input strings.Builder
+	input.WriteString(inputHeader)
+	for i, path := range []string{"/same", "/other", "/category"} {
+		fmt.Fprintf(&input, "%d,old,new,,,,,,%s/old,,,%s%s,,\n", i, site.URL, site.URL, path)
+	}
+	require.NoError(t, os.WriteFile(cfg.Input, []byte(input.String()), 0o600))
+
+	require.NoError(t, run(context.Background(), cfg, ModeAnalyze))
+
+	records := readOutput(t, cfg.Output)
+	require.Len(t, records, 4)
+	bySku := map[string]map[string]string{}
+	for _, record := range records[1:] {
+		row := map[string]string{}
+		for i, name := range records[0] {
+			row[name] = record[i]
+		}
+		bySku[record[0]] = row
+	}
+
+	require.Equal(t, "ANALISAR", bySku["0"]["Status"])
+	require.Equal(t, "PRODUTO_DIFERENTE", bySku["1"]["Status"])
+	require.Equal(t, "0.00", bySku["1"]["Similarity"])
+	require.Equal(t, "PARA_CATEGORIA", bySku["2"]["Status"])
+}
@@ -1,6 +1,9 @@
 package metadata
 
-import "strconv"
+import (
+	"strconv"
+	"time"
+)
 
 type Map map[string]interface{}
 
@@ -76,3 +79,35 @@ func (m Map) AsFloat64(key string, defaultValue float64) float64 {
 	}
 	return defaultValue
 }
+
+func (m Map) AsDuration(key string, defaultValue time.Duration) time.Duration {
+	if v, ok := m[key]; ok {
+		switch vv := v.(type) {
+		case time.Duration:
+			return vv
+		case string:
+			d, err := time.ParseDuration(vv)
+			if err != nil {
+				return 0
+			}
+			return d
+		}
+	}
+	return defaultValue
+}
+
+func (m Map) AsBool(key string, defaultValue bool) bool {
+	if v, ok := m[key]; ok {
+		switch vv := v.(type) {
+		case bool:
+			return vv
+		case string:
+			b, err := strconv.ParseBool(vv)
+			if err != nil {
+				return defaultValue
+			}
+			return b
+		}
+	}
+	return defaultValue
+}
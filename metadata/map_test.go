@@ -2,6 +2,7 @@ package metadata_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/castmetal/cliquefarma-analize-redirect-csv/metadata"
 	"github.com/stretchr/testify/require"
@@ -188,3 +189,81 @@ func TestMetadataAsFloat64(t *testing.T) {
 		})
 	}
 }
+
+func TestMetadataAsDuration(t *testing.T) {
+	testCases := []struct {
+		desc string
+
+		view     metadata.Map
+		validate func(t *testing.T, m metadata.Map)
+	}{
+		{
+			desc: "value not existing, using default",
+			view: metadata.Map{},
+			validate: func(t *testing.T, m metadata.Map) {
+				got := m.AsDuration("value", time.Second)
+				require.Equal(t, time.Second, got)
+			},
+		},
+		{
+			desc: "value as string, parsed as duration",
+			view: metadata.Map{"value": "1m30s"},
+			validate: func(t *testing.T, m metadata.Map) {
+				got := m.AsDuration("value", time.Second)
+				require.Equal(t, 90*time.Second, got)
+			},
+		},
+		{
+			desc: "value as time.Duration",
+			view: metadata.Map{"value": 2 * time.Second},
+			validate: func(t *testing.T, m metadata.Map) {
+				got := m.AsDuration("value", time.Second)
+				require.Equal(t, 2*time.Second, got)
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			tC.validate(t, tC.view)
+		})
+	}
+}
+
+func TestMetadataAsBool(t *testing.T) {
+	testCases := []struct {
+		desc string
+
+		view     metadata.Map
+		validate func(t *testing.T, m metadata.Map)
+	}{
+		{
+			desc: "value not existing, using default",
+			view: metadata.Map{},
+			validate: func(t *testing.T, m metadata.Map) {
+				got := m.AsBool("value", true)
+				require.True(t, got)
+			},
+		},
+		{
+			desc: "value as string, parsed as bool",
+			view: metadata.Map{"value": "false"},
+			validate: func(t *testing.T, m metadata.Map) {
+				got := m.AsBool("value", true)
+				require.False(t, got)
+			},
+		},
+		{
+			desc: "invalid string, using default",
+			view: metadata.Map{"value": "maybe"},
+			validate: func(t *testing.T, m metadata.Map) {
+				got := m.AsBool("value", true)
+				require.True(t, got)
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			tC.validate(t, tC.view)
+		})
+	}
+}
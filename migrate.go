@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/migration"
+)
+
+// runMigrate writes the slug changes of the analysis rows with the wanted
+// status as up and down SQL scripts, and optionally applies them.
+func runMigrate(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	input := fs.String("input", "output.csv", "analysis output to read the slug changes from")
+	status := fs.String("status", "ALTERAR", "comma separated statuses of the rows migrated")
+	up := fs.String("up", "migration_up.sql", "file to write the UPDATE statements to")
+	down := fs.String("down", "migration_down.sql", "file to write the rollback statements to")
+	dialect := fs.String("dialect", string(migration.Postgres), fmt.Sprintf("SQL dialect, one of %q", migration.Dialects()))
+	table := fs.String("table", "products", "table holding the products")
+	skuColumn := fs.String("sku-column", "sku", "column holding the Sku")
+	slugColumn := fs.String("slug-column", "slug", "column holding the slug")
+	apply := fs.Bool("apply", false, "apply the changes to the database given by -dsn, in a transaction")
+	dryRun := fs.Bool("dry-run", false, "with -apply, roll the transaction back and only report what would change")
+	dsn := fs.String("dsn", "", "database connection string used by -apply")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *apply && *dsn == "" {
+		return fmt.Errorf("-apply requires -dsn")
+	}
+	if !knownDialect(migration.Dialect(*dialect)) {
+		// Checked before any script is written, a good one being kept.
+		return fmt.Errorf("migrate: unknown dialect %q, expected one of %q", *dialect, migration.Dialects())
+	}
+
+	changes, err := readSlugChanges(*input, strings.Split(*status, ","))
+	if err != nil {
+		return err
+	}
+
+	target := migration.Target{Table: *table, SkuColumn: *skuColumn, SlugColumn: *slugColumn}
+	if err := writeScript(*up, func(f *os.File) error {
+		return migration.WriteUp(f, migration.Dialect(*dialect), target, changes)
+	}); err != nil {
+		return err
+	}
+	if err := writeScript(*down, func(f *os.File) error {
+		return migration.WriteDown(f, migration.Dialect(*dialect), target, changes)
+	}); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %d slug changes to %s and their rollback to %s\n", len(changes), *up, *down)
+
+	if !*apply {
+		return nil
+	}
+
+	db, err := migration.Open(migration.Dialect(*dialect), *dsn)
+	if err != nil {
+		return err
+	}
+	result, err := migration.Apply(ctx, db, target, changes, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	verb := "updated"
+	if *dryRun {
+		verb = "dry run: would update"
+	}
+	fmt.Printf("%s %d rows\n", verb, result.Updated)
+	for _, c := range result.Missing {
+		fmt.Printf("  no row with sku %q and slug %q\n", c.Sku, c.OldSlug)
+	}
+	return nil
+}
+
+func writeScript(path string, write func(f *os.File) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed creating file: %w", err)
+	}
+	defer file.Close()
+
+	if err := write(file); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// readSlugChanges reads the Sku, Old Slug and New Slug of the analysis rows
+// whose Status is one of statuses. Rows of the same Sku are merged, and a
+// Sku with two different slug changes is an error, as it needs a review.
+func readSlugChanges(path string, statuses []string) ([]migration.Change, error) {
+	rows, err := readAnalysis(path, statuses, "Sku", "Old Slug", "New Slug")
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]migration.Change{}
+	var changes []migration.Change
+	for _, row := range rows {
+		change := migration.Change{Sku: row["Sku"], OldSlug: row["Old Slug"], NewSlug: row["New Slug"]}
+		if change.OldSlug == "" || change.NewSlug == "" || change.OldSlug == change.NewSlug {
+			continue
+		}
+		if previous, ok := seen[change.Sku]; ok {
+			if previous != change {
+				return nil, fmt.Errorf("sku %q has two slug changes: %q -> %q and %q -> %q",
+					change.Sku, previous.OldSlug, previous.NewSlug, change.OldSlug, change.NewSlug)
+			}
+			continue
+		}
+		seen[change.Sku] = change
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+func knownDialect(dialect migration.Dialect) bool {
+	for _, d := range migration.Dialects() {
+		if d == dialect {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeAnalysis(t *testing.T, dir string, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, "output.csv")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestRunMigrate(t *testing.T) {
+	dir := t.TempDir()
+	input := writeAnalysis(t, dir, "Sku,De,Para,Status,Old Slug,New Slug\n"+
+		"1,https://a/x:g,https://a/x-g,ALTERAR,x:g,x-g\n"+
+		"1,https://b/x:g,https://b/x-g,ALTERAR,x:g,x-g\n"+
+		"2,https://a/y:g,https://a/y-g,REDIRECIONAR,y:g,y-g\n")
+	up := filepath.Join(dir, "up.sql")
+	down := filepath.Join(dir, "down.sql")
+
+	err := runMigrate(context.Background(), []string{"-input", input, "-up", up, "-down", down, "-dialect", "sqlite"})
+	require.NoError(t, err)
+
+	got, err := os.ReadFile(up)
+	require.NoError(t, err)
+	require.Contains(t, string(got), `UPDATE "products" SET "slug" = 'x-g' WHERE "sku" = '1' AND "slug" = 'x:g';`)
+	require.NotContains(t, string(got), "y-g")
+
+	got, err = os.ReadFile(down)
+	require.NoError(t, err)
+	require.Contains(t, string(got), `UPDATE "products" SET "slug" = 'x:g' WHERE "sku" = '1' AND "slug" = 'x-g';`)
+}
+
+func TestRunMigrateUnknownDialect(t *testing.T) {
+	dir := t.TempDir()
+	input := writeAnalysis(t, dir, "Sku,De,Para,Status,Old Slug,New Slug\n1,https://a/x:g,https://a/x-g,ALTERAR,x:g,x-g\n")
+	up := filepath.Join(dir, "up.sql")
+	down := filepath.Join(dir, "down.sql")
+	require.NoError(t, os.WriteFile(up, []byte("-- previous script\n"), 0o600))
+
+	err := runMigrate(context.Background(), []string{"-input", input, "-up", up, "-down", down, "-dialect", "oracle"})
+	require.Error(t, err)
+
+	got, err := os.ReadFile(up)
+	require.NoError(t, err)
+	require.Equal(t, "-- previous script\n", string(got), "up script left untouched")
+	require.NoFileExists(t, down)
+}
+
+func TestRunMigrateConflictingChanges(t *testing.T) {
+	dir := t.TempDir()
+	input := writeAnalysis(t, dir, "Sku,De,Para,Status,Old Slug,New Slug\n"+
+		"1,https://a/x:g,https://a/x-g,ALTERAR,x:g,x-g\n"+
+		"1,https://a/x:g,https://a/x-gg,ALTERAR,x:g,x-gg\n")
+
+	err := runMigrate(context.Background(), []string{"-input", input, "-up", filepath.Join(dir, "up.sql"), "-down", filepath.Join(dir, "down.sql")})
+	require.ErrorContains(t, err, "two slug changes")
+}
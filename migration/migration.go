@@ -0,0 +1,180 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// Dialect is the SQL flavor statements are written in.
+type Dialect string
+
+const (
+	Postgres Dialect = "postgres"
+	MySQL    Dialect = "mysql"
+	SQLite   Dialect = "sqlite"
+)
+
+// Dialects lists every supported dialect.
+func Dialects() []Dialect {
+	return []Dialect{Postgres, MySQL, SQLite}
+}
+
+// errDryRun rolls back the transaction of a dry run.
+var errDryRun = errors.New("dry run")
+
+// Change is the slug of a product that has to be updated.
+type Change struct {
+	Sku     string
+	OldSlug string
+	NewSlug string
+}
+
+// Target is the table and columns holding the product slugs.
+type Target struct {
+	Table      string
+	SkuColumn  string
+	SlugColumn string
+}
+
+// Result is what applying the changes did, or would do on a dry run.
+type Result struct {
+	Updated int
+	// Missing are the changes matching no row, because the Sku does not exist
+	// or its slug is no longer the old one.
+	Missing []Change
+}
+
+// WriteUp writes the UPDATE statements moving every product to its new slug.
+func WriteUp(w io.Writer, dialect Dialect, target Target, changes []Change) error {
+	return write(w, dialect, target, changes, false)
+}
+
+// WriteDown writes the UPDATE statements moving every product back to its old slug.
+func WriteDown(w io.Writer, dialect Dialect, target Target, changes []Change) error {
+	return write(w, dialect, target, changes, true)
+}
+
+func write(w io.Writer, dialect Dialect, target Target, changes []Change, rollback bool) error {
+	q, err := newQuoter(dialect)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	direction := "new"
+	if rollback {
+		direction = "old"
+	}
+	fmt.Fprintf(&b, "-- Moves %d products of %s to their %s slug.\n", len(changes), target.Table, direction)
+	b.WriteString("BEGIN;\n\n")
+	for _, c := range changes {
+		from, to := c.OldSlug, c.NewSlug
+		if rollback {
+			from, to = to, from
+		}
+		// Matching the current slug keeps the statement from overwriting
+		// a slug changed by someone else since the analysis.
+		fmt.Fprintf(&b, "UPDATE %s SET %s = %s WHERE %s = %s AND %s = %s;\n",
+			q.identifier(target.Table),
+			q.identifier(target.SlugColumn), q.value(to),
+			q.identifier(target.SkuColumn), q.value(c.Sku),
+			q.identifier(target.SlugColumn), q.value(from),
+		)
+	}
+	b.WriteString("\nCOMMIT;\n")
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+type quoter struct {
+	identifierQuote string
+	escapeBackslash bool
+}
+
+func newQuoter(dialect Dialect) (quoter, error) {
+	switch dialect {
+	case Postgres, SQLite:
+		return quoter{identifierQuote: `"`}, nil
+	case MySQL:
+		return quoter{identifierQuote: "`", escapeBackslash: true}, nil
+	}
+	return quoter{}, fmt.Errorf("migration: unknown dialect %q, expected one of %q", dialect, Dialects())
+}
+
+// identifier quotes a table or column name, which may be schema qualified.
+func (q quoter) identifier(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = q.identifierQuote + strings.ReplaceAll(part, q.identifierQuote, q.identifierQuote+q.identifierQuote) + q.identifierQuote
+	}
+	return strings.Join(parts, ".")
+}
+
+func (q quoter) value(s string) string {
+	if q.escapeBackslash {
+		s = strings.ReplaceAll(s, `\`, `\\`)
+	}
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// Open connects to the database with the gorm driver of dialect.
+func Open(dialect Dialect, dsn string) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch dialect {
+	case Postgres:
+		dialector = postgres.Open(dsn)
+	case MySQL:
+		dialector = mysql.Open(dsn)
+	case SQLite:
+		dialector = sqlite.Open(dsn)
+	default:
+		return nil, fmt.Errorf("migration: unknown dialect %q, expected one of %q", dialect, Dialects())
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: gormlogger.Default.LogMode(gormlogger.Silent)})
+	if err != nil {
+		return nil, fmt.Errorf("migration: could not connect to the database: %w", err)
+	}
+	return db, nil
+}
+
+// Apply updates the slugs inside a single transaction. On a dry run the
+// statements are executed and rolled back, reporting what would change.
+func Apply(ctx context.Context, db *gorm.DB, target Target, changes []Change, dryRun bool) (Result, error) {
+	var result Result
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, c := range changes {
+			res := tx.Table(target.Table).
+				Where(clause.Eq{Column: clause.Column{Name: target.SkuColumn}, Value: c.Sku}).
+				Where(clause.Eq{Column: clause.Column{Name: target.SlugColumn}, Value: c.OldSlug}).
+				Update(target.SlugColumn, c.NewSlug)
+			if res.Error != nil {
+				return fmt.Errorf("migration: could not update sku %q: %w", c.Sku, res.Error)
+			}
+			if res.RowsAffected == 0 {
+				result.Missing = append(result.Missing, c)
+				continue
+			}
+			result.Updated += int(res.RowsAffected)
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return Result{}, err
+	}
+	return result, nil
+}
@@ -0,0 +1,134 @@
+package migration_test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/migration"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+var (
+	target  = migration.Target{Table: "products", SkuColumn: "sku", SlugColumn: "slug"}
+	changes = []migration.Change{
+		{Sku: "0000000028014", OldSlug: "scrub-azul-tamanho:g", NewSlug: "scrub-azul-tamanho-g"},
+		{Sku: "0000000028015", OldSlug: "pomada d'agua", NewSlug: "pomada-dagua"},
+	}
+)
+
+func TestWrite(t *testing.T) {
+	testCases := []struct {
+		desc string
+
+		dialect  migration.Dialect
+		write    func(w *strings.Builder, dialect migration.Dialect) error
+		expected string
+	}{
+		{
+			desc:    "postgres up",
+			dialect: migration.Postgres,
+			write: func(w *strings.Builder, dialect migration.Dialect) error {
+				return migration.WriteUp(w, dialect, target, changes)
+			},
+			expected: `-- Moves 2 products of products to their new slug.
+BEGIN;
+
+UPDATE "products" SET "slug" = 'scrub-azul-tamanho-g' WHERE "sku" = '0000000028014' AND "slug" = 'scrub-azul-tamanho:g';
+UPDATE "products" SET "slug" = 'pomada-dagua' WHERE "sku" = '0000000028015' AND "slug" = 'pomada d''agua';
+
+COMMIT;
+`,
+		},
+		{
+			desc:    "mysql down",
+			dialect: migration.MySQL,
+			write: func(w *strings.Builder, dialect migration.Dialect) error {
+				return migration.WriteDown(w, dialect, target, changes)
+			},
+			expected: "-- Moves 2 products of products to their old slug.\nBEGIN;\n\n" +
+				"UPDATE `products` SET `slug` = 'scrub-azul-tamanho:g' WHERE `sku` = '0000000028014' AND `slug` = 'scrub-azul-tamanho-g';\n" +
+				"UPDATE `products` SET `slug` = 'pomada d''agua' WHERE `sku` = '0000000028015' AND `slug` = 'pomada-dagua';\n" +
+				"\nCOMMIT;\n",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			var out strings.Builder
+			require.NoError(t, tC.write(&out, tC.dialect))
+			require.Equal(t, tC.expected, out.String())
+		})
+	}
+}
+
+func TestWriteUnknownDialect(t *testing.T) {
+	var out strings.Builder
+	require.Error(t, migration.WriteUp(&out, migration.Dialect("oracle"), target, changes))
+}
+
+type product struct {
+	Sku  string
+	Slug string
+}
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := migration.Open(migration.SQLite, filepath.Join(t.TempDir(), "catalog.db"))
+	require.NoError(t, err)
+	require.NoError(t, db.Table("products").AutoMigrate(&product{}))
+	require.NoError(t, db.Table("products").Create([]product{
+		{Sku: "0000000028014", Slug: "scrub-azul-tamanho:g"},
+		{Sku: "0000000028015", Slug: "already-changed"},
+	}).Error)
+	return db
+}
+
+func slugs(t *testing.T, db *gorm.DB) map[string]string {
+	t.Helper()
+	var products []product
+	require.NoError(t, db.Table("products").Find(&products).Error)
+	got := map[string]string{}
+	for _, p := range products {
+		got[p.Sku] = p.Slug
+	}
+	return got
+}
+
+func TestApply(t *testing.T) {
+	testCases := []struct {
+		desc string
+
+		dryRun   bool
+		expected map[string]string
+	}{
+		{
+			desc:   "dry run rolls back",
+			dryRun: true,
+			expected: map[string]string{
+				"0000000028014": "scrub-azul-tamanho:g",
+				"0000000028015": "already-changed",
+			},
+		},
+		{
+			desc:   "applying",
+			dryRun: false,
+			expected: map[string]string{
+				"0000000028014": "scrub-azul-tamanho-g",
+				"0000000028015": "already-changed",
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			db := newTestDB(t)
+
+			result, err := migration.Apply(context.Background(), db, target, changes, tC.dryRun)
+			require.NoError(t, err)
+			require.Equal(t, 1, result.Updated)
+			require.Equal(t, []migration.Change{changes[1]}, result.Missing)
+			require.Equal(t, tC.expected, slugs(t, db))
+		})
+	}
+}
@@ -0,0 +1,280 @@
+package product
+
+import (
+	"encoding/json"
+	"html"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/seo"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/slug"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/soft404"
+)
+
+// Kinds of page told apart by their markup.
+const (
+	KindProduct  = "product"
+	KindCategory = "category"
+)
+
+var (
+	h1Tag       = regexp.MustCompile(`(?is)<h1[^>]*>(.*?)</h1>`)
+	jsonLD      = regexp.MustCompile(`(?is)<script[^>]*type\s*=\s*["']?application/ld\+json["']?[^>]*>(.*?)</script>`)
+	itempropTag = regexp.MustCompile(`(?is)<[a-z][a-z0-9]*\b[^>]*\bitemprop\s*=[^>]*>`)
+	priceText   = regexp.MustCompile(`[0-9][0-9.,]*`)
+)
+
+// categoryTypes are the schema.org types of pages listing products.
+var categoryTypes = map[string]bool{"ItemList": true, "CollectionPage": true, "SearchResultsPage": true, "OfferCatalog": true}
+
+// weights of each field in the similarity, the product name and Sku telling
+// the most.
+const (
+	weightSku   = 2
+	weightName  = 2
+	weightTitle = 1
+	weightH1    = 1
+	weightPrice = 1
+)
+
+// Page is what a page tells about the product it shows.
+type Page struct {
+	Title string
+	H1    string
+	// Name, Sku and Price come from the schema.org Product of the page, or
+	// from its Open Graph and itemprop tags.
+	Name  string
+	Sku   string
+	Price float64
+	// Kind is KindProduct, KindCategory or empty when the page tells neither.
+	Kind string
+}
+
+// Extract reads the title, H1 and product of an HTML page.
+func Extract(body string) Page {
+	page := Page{Title: soft404.Title(body)}
+	if match := h1Tag.FindStringSubmatch(body); match != nil {
+		page.H1 = soft404.Text(match[1])
+	}
+
+	for _, match := range jsonLD.FindAllStringSubmatch(body, -1) {
+		var data interface{}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(match[1])), &data); err != nil {
+			continue
+		}
+		page.readJSONLD(data)
+	}
+
+	for _, tag := range seo.MetaTags(body) {
+		attrs := seo.Attributes(tag)
+		content := attrs["content"]
+		switch strings.ToLower(attrs["property"]) {
+		case "og:type":
+			switch strings.ToLower(content) {
+			case "product":
+				page.setKind(KindProduct)
+			case "product.group":
+				page.setKind(KindCategory)
+			}
+		case "og:title", "product:title":
+			page.setName(content)
+		case "product:price:amount":
+			page.setPrice(content)
+		case "product:retailer_item_id":
+			page.setSku(content)
+		}
+	}
+
+	for _, tag := range itempropTag.FindAllString(body, -1) {
+		attrs := seo.Attributes(tag)
+		content, ok := attrs["content"]
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(attrs["itemprop"]) {
+		case "sku":
+			page.setSku(content)
+		case "price":
+			page.setPrice(content)
+			page.setKind(KindProduct)
+		}
+	}
+	return page
+}
+
+// readJSONLD walks a JSON-LD document looking for a Product, or a list of
+// products.
+func (p *Page) readJSONLD(data interface{}) {
+	switch v := data.(type) {
+	case []interface{}:
+		for _, item := range v {
+			p.readJSONLD(item)
+		}
+	case map[string]interface{}:
+		if graph, ok := v["@graph"]; ok {
+			p.readJSONLD(graph)
+		}
+		for _, t := range types(v["@type"]) {
+			switch {
+			case t == "Product":
+				p.setKind(KindProduct)
+				p.setName(text(v["name"]))
+				p.setSku(text(v["sku"]))
+				p.readOffers(v["offers"])
+			case categoryTypes[t]:
+				p.setKind(KindCategory)
+			}
+		}
+	}
+}
+
+func (p *Page) readOffers(offers interface{}) {
+	switch v := offers.(type) {
+	case []interface{}:
+		for _, offer := range v {
+			p.readOffers(offer)
+		}
+	case map[string]interface{}:
+		p.setPrice(text(v["price"]))
+		p.setPrice(text(v["lowPrice"]))
+	}
+}
+
+// The setters keep the first value found, the JSON-LD being read first.
+
+func (p *Page) setKind(kind string) {
+	// A product page may list related products, the product wins.
+	if p.Kind == "" || kind == KindProduct {
+		p.Kind = kind
+	}
+}
+
+func (p *Page) setName(name string) {
+	if p.Name == "" {
+		p.Name = strings.Join(strings.Fields(name), " ")
+	}
+}
+
+func (p *Page) setSku(sku string) {
+	if p.Sku == "" {
+		p.Sku = strings.TrimSpace(sku)
+	}
+}
+
+func (p *Page) setPrice(price string) {
+	if p.Price == 0 {
+		p.Price = ParsePrice(price)
+	}
+}
+
+// ParsePrice reads a price like "19.90", "19,90" or "R$ 1.299,90", 0 when
+// there is none.
+func ParsePrice(s string) float64 {
+	s = priceText.FindString(s)
+	s = strings.TrimRight(s, ".,")
+	comma, dot := strings.LastIndexByte(s, ','), strings.LastIndexByte(s, '.')
+	switch {
+	case comma > dot:
+		// Brazilian format, dots grouping thousands.
+		s = strings.ReplaceAll(s, ".", "")
+		s = strings.Replace(s, ",", ".", 1)
+	default:
+		s = strings.ReplaceAll(s, ",", "")
+	}
+	price, err := strconv.ParseFloat(s, 64)
+	if err != nil || price < 0 {
+		return 0
+	}
+	return price
+}
+
+// Similarity returns how alike the products of two pages are, from 0 to 1,
+// averaging the fields both pages have: Sku, name, title, H1 and price. It
+// returns false when they have none in common.
+func Similarity(a, b Page) (float64, bool) {
+	var sum, weights float64
+	add := func(weight float64, score float64) {
+		sum += weight * score
+		weights += weight
+	}
+
+	if a.Sku != "" && b.Sku != "" {
+		score := 0.0
+		if strings.EqualFold(a.Sku, b.Sku) {
+			score = 1
+		}
+		add(weightSku, score)
+	}
+	if a.Name != "" && b.Name != "" {
+		add(weightName, words(a.Name, b.Name))
+	}
+	if a.Title != "" && b.Title != "" {
+		add(weightTitle, words(a.Title, b.Title))
+	}
+	if a.H1 != "" && b.H1 != "" {
+		add(weightH1, words(a.H1, b.H1))
+	}
+	if a.Price > 0 && b.Price > 0 {
+		add(weightPrice, 1-math.Abs(a.Price-b.Price)/math.Max(a.Price, b.Price))
+	}
+
+	if weights == 0 {
+		return 0, false
+	}
+	return sum / weights, true
+}
+
+// words returns the share of words found in both a and b, ignoring case and
+// accents.
+func words(a, b string) float64 {
+	setA, setB := wordSet(a), wordSet(b)
+	if len(setA) == 0 && len(setB) == 0 {
+		return 1
+	}
+	common := 0
+	for w := range setA {
+		if setB[w] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(setA)+len(setB)-common)
+}
+
+func wordSet(s string) map[string]bool {
+	set := map[string]bool{}
+	for _, w := range strings.Split(slug.Generate(s), "-") {
+		if w != "" {
+			set[w] = true
+		}
+	}
+	return set
+}
+
+func types(v interface{}) []string {
+	switch t := v.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		var list []string
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// text returns a JSON-LD string or number as text.
+func text(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return html.UnescapeString(t)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	}
+	return ""
+}
@@ -0,0 +1,118 @@
+package product_test
+
+import (
+	"testing"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/product"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtract(t *testing.T) {
+	testCases := []struct {
+		desc string
+
+		body     string
+		expected product.Page
+	}{
+		{
+			desc: "json-ld product",
+			body: `<html><head><title>Dipirona 500mg 10 Comprimidos | Cliquefarma</title>
+				<script type="application/ld+json">{"@context":"https://schema.org","@graph":[
+					{"@type":"BreadcrumbList"},
+					{"@type":"Product","name":"Dipirona 500mg 10 Comprimidos","sku":12345,
+					 "offers":{"@type":"Offer","price":"9.90","priceCurrency":"BRL"}}]}</script>
+				</head><body><h1 class="name">Dipirona <b>500mg</b></h1></body></html>`,
+			expected: product.Page{
+				Title: "Dipirona 500mg 10 Comprimidos | Cliquefarma",
+				H1:    "Dipirona 500mg",
+				Name:  "Dipirona 500mg 10 Comprimidos",
+				Sku:   "12345",
+				Price: 9.9,
+				Kind:  product.KindProduct,
+			},
+		},
+		{
+			desc: "open graph and itemprop",
+			body: `<head><meta property="og:type" content="product">
+				<meta property="og:title" content="Protetor Solar FPS 50">
+				<meta property="product:price:amount" content="R$ 1.299,90"></head>
+				<body><span itemprop="sku" content="ABC-1"></span></body>`,
+			expected: product.Page{Name: "Protetor Solar FPS 50", Sku: "ABC-1", Price: 1299.9, Kind: product.KindProduct},
+		},
+		{
+			desc: "category page",
+			body: `<title>Analgésicos</title><script type="application/ld+json">[{"@type":["CollectionPage"]},
+				{"@type":"ItemList","itemListElement":[]}]</script><h1>Analgésicos</h1>`,
+			expected: product.Page{Title: "Analgésicos", H1: "Analgésicos", Kind: product.KindCategory},
+		},
+		{
+			desc:     "invalid json-ld",
+			body:     `<title>Busca</title><script type="application/ld+json">{"@type":"Product",</script>`,
+			expected: product.Page{Title: "Busca"},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			require.Equal(t, tC.expected, product.Extract(tC.body))
+		})
+	}
+}
+
+func TestParsePrice(t *testing.T) {
+	testCases := []struct {
+		desc string
+
+		price    string
+		expected float64
+	}{
+		{desc: "dot", price: "19.90", expected: 19.9},
+		{desc: "comma", price: "19,90", expected: 19.9},
+		{desc: "currency and thousands", price: "R$ 1.299,90", expected: 1299.9},
+		{desc: "english thousands", price: "1,299.90", expected: 1299.9},
+		{desc: "none", price: "grátis", expected: 0},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			require.InDelta(t, tC.expected, product.ParsePrice(tC.price), 0.001)
+		})
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	dipirona := product.Page{
+		Title: "Dipirona 500mg 10 Comprimidos | Cliquefarma",
+		H1:    "Dipirona 500mg 10 Comprimidos",
+		Name:  "Dipirona 500mg 10 Comprimidos",
+		Sku:   "12345",
+		Price: 9.9,
+	}
+
+	testCases := []struct {
+		desc string
+
+		to       product.Page
+		min, max float64
+		ok       bool
+	}{
+		{desc: "same product", to: dipirona, min: 1, max: 1, ok: true},
+		{
+			desc: "same product renamed",
+			to:   product.Page{Title: "Dipirona Sódica 500mg 10 Comprimidos | Cliquefarma", H1: "Dipirona Sódica 500mg 10 Comprimidos", Name: "Dipirona Sódica 500mg 10 Comprimidos", Sku: "12345", Price: 10.5},
+			min:  0.8, max: 1, ok: true,
+		},
+		{
+			desc: "another product",
+			to:   product.Page{Title: "Protetor Solar FPS 50 | Cliquefarma", H1: "Protetor Solar FPS 50", Name: "Protetor Solar FPS 50", Sku: "999", Price: 59.9},
+			min:  0, max: 0.2, ok: true,
+		},
+		{desc: "nothing to compare", to: product.Page{Kind: product.KindCategory}, ok: false},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			score, ok := product.Similarity(dipirona, tC.to)
+			require.Equal(t, tC.ok, ok)
+			require.GreaterOrEqual(t, score, tC.min)
+			require.LessOrEqual(t, score, tC.max)
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package ratelimit
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Config holds the politeness settings applied to every host.
+type Config struct {
+	// RequestsPerSecond is the rate of requests sent to a host, 0 for no limit.
+	RequestsPerSecond float64
+	// Burst is the number of requests a host can get at once before the rate applies.
+	Burst int
+	// MaxInFlight is the number of requests running on a host at once, 0 for no limit.
+	MaxInFlight int
+	// RobotsCrawlDelay reads the Crawl-delay of the host robots.txt and slows
+	// the rate down to it when it is lower.
+	RobotsCrawlDelay bool
+	// UserAgent picks the robots.txt group the Crawl-delay is read from.
+	UserAgent string
+}
+
+// Transport is an http.RoundTripper limiting the requests sent to each host.
+// A single Transport must be shared by every client for the limits to hold.
+type Transport struct {
+	base   http.RoundTripper
+	config Config
+
+	mu    sync.Mutex
+	hosts map[string]*host
+}
+
+type host struct {
+	ready    chan struct{}
+	limiter  *rate.Limiter
+	inFlight chan struct{}
+}
+
+// NewTransport wraps base with the limits of config.
+func NewTransport(base http.RoundTripper, config Config) *Transport {
+	if config.Burst < 1 {
+		config.Burst = 1
+	}
+	return &Transport{
+		base:   base,
+		config: config,
+		hosts:  map[string]*host{},
+	}
+}
+
+// RoundTrip waits for a free slot and a token of the request host before
+// sending it. The slot is released when the response body is closed.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	h, err := t.host(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.inFlight != nil {
+		select {
+		case h.inFlight <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	release := func() {
+		if h.inFlight != nil {
+			<-h.inFlight
+		}
+	}
+
+	if err := h.limiter.Wait(ctx); err != nil {
+		release()
+		return nil, err
+	}
+
+	res, err := t.base.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	res.Body = &releasingBody{ReadCloser: res.Body, release: release}
+	return res, nil
+}
+
+// host returns the limits of the request host, creating them on its first
+// request. Concurrent first requests wait for robots.txt to be read once.
+func (t *Transport) host(ctx context.Context, req *http.Request) (*host, error) {
+	key := strings.ToLower(req.URL.Scheme + "://" + req.URL.Host)
+
+	t.mu.Lock()
+	h, ok := t.hosts[key]
+	if !ok {
+		h = &host{ready: make(chan struct{})}
+		t.hosts[key] = h
+	}
+	t.mu.Unlock()
+
+	if ok {
+		select {
+		case <-h.ready:
+			return h, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	limit := rate.Inf
+	if t.config.RequestsPerSecond > 0 {
+		limit = rate.Limit(t.config.RequestsPerSecond)
+	}
+	burst := t.config.Burst
+	if t.config.RobotsCrawlDelay {
+		if delay := t.crawlDelay(ctx, key); delay > 0 {
+			if robotsLimit := rate.Every(delay); robotsLimit < limit {
+				limit, burst = robotsLimit, 1
+			}
+		}
+	}
+
+	h.limiter = rate.NewLimiter(limit, burst)
+	if t.config.MaxInFlight > 0 {
+		h.inFlight = make(chan struct{}, t.config.MaxInFlight)
+	}
+	close(h.ready)
+	return h, nil
+}
+
+// crawlDelay reads the Crawl-delay of the robots.txt of origin. Any failure
+// to read it means no delay.
+func (t *Transport) crawlDelay(ctx context.Context, origin string) time.Duration {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, origin+"/robots.txt", nil)
+	if err != nil {
+		return 0
+	}
+	if t.config.UserAgent != "" {
+		req.Header.Set("User-Agent", t.config.UserAgent)
+	}
+	res, err := t.base.RoundTrip(req)
+	if err != nil {
+		return 0
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return 0
+	}
+
+	robots, err := io.ReadAll(io.LimitReader(res.Body, 512<<10))
+	if err != nil {
+		return 0
+	}
+	return CrawlDelay(string(robots), t.config.UserAgent)
+}
+
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,141 @@
+package ratelimit_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/ratelimit"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCrawlDelay(t *testing.T) {
+	testCases := []struct {
+		desc string
+
+		robots    string
+		userAgent string
+		expected  time.Duration
+	}{
+		{
+			desc:      "no crawl delay",
+			robots:    "User-agent: *\nDisallow: /checkout\n",
+			userAgent: "cliquefarmabot v1.0.0",
+			expected:  0,
+		},
+		{
+			desc:      "wildcard group",
+			robots:    "User-agent: *\nCrawl-delay: 2\n",
+			userAgent: "cliquefarmabot v1.0.0",
+			expected:  2 * time.Second,
+		},
+		{
+			desc: "own group wins over wildcard",
+			robots: `# robots of the storefront
+User-agent: *
+Crawl-delay: 5
+
+User-agent: googlebot
+User-agent: CliqueFarmaBot
+Crawl-delay: 0.5 # be quick
+`,
+			userAgent: "cliquefarmabot/1.0",
+			expected:  500 * time.Millisecond,
+		},
+		{
+			desc:      "other bot group ignored",
+			robots:    "User-agent: googlebot\nCrawl-delay: 1\n",
+			userAgent: "cliquefarmabot v1.0.0",
+			expected:  0,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			require.Equal(t, tC.expected, ratelimit.CrawlDelay(tC.robots, tC.userAgent))
+		})
+	}
+}
+
+func get(t *testing.T, client *http.Client, url string) {
+	t.Helper()
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+	require.NoError(t, err)
+	res, err := client.Do(req)
+	require.NoError(t, err)
+	res.Body.Close()
+}
+
+func TestTransportMaxInFlight(t *testing.T) {
+	var running, maxRunning int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: ratelimit.NewTransport(http.DefaultTransport, ratelimit.Config{MaxInFlight: 2})}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			get(t, client, server.URL)
+		}()
+	}
+	wg.Wait()
+
+	require.LessOrEqual(t, atomic.LoadInt32(&maxRunning), int32(2))
+}
+
+func TestTransportRate(t *testing.T) {
+	testCases := []struct {
+		desc string
+
+		config  ratelimit.Config
+		robots  string
+		minimum time.Duration
+	}{
+		{
+			desc:    "requests per second",
+			config:  ratelimit.Config{RequestsPerSecond: 50, Burst: 1},
+			minimum: 80 * time.Millisecond,
+		},
+		{
+			desc:    "robots crawl delay slower than the rate",
+			config:  ratelimit.Config{RequestsPerSecond: 1000, Burst: 10, RobotsCrawlDelay: true, UserAgent: "cliquefarmabot v1.0.0"},
+			robots:  "User-agent: *\nCrawl-delay: 0.04\n",
+			minimum: 160 * time.Millisecond,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/robots.txt" {
+					fmt.Fprint(w, tC.robots)
+				}
+			}))
+			defer server.Close()
+
+			client := &http.Client{Transport: ratelimit.NewTransport(http.DefaultTransport, tC.config)}
+
+			started := time.Now()
+			for i := 0; i < 5; i++ {
+				get(t, client, server.URL+"/page")
+			}
+			require.GreaterOrEqual(t, time.Since(started), tC.minimum)
+		})
+	}
+}
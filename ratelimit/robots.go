@@ -0,0 +1,89 @@
+package ratelimit
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CrawlDelay returns the Crawl-delay robots.txt gives to userAgent. The group
+// naming the product token of userAgent wins over the "*" group.
+func CrawlDelay(robots string, userAgent string) time.Duration {
+	token := productToken(userAgent)
+
+	var (
+		agents   []string
+		inRules  bool
+		matched  = time.Duration(-1)
+		wildcard = time.Duration(-1)
+	)
+	for _, line := range strings.Split(robots, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// A user-agent after rules starts a new group.
+			if inRules {
+				agents, inRules = nil, false
+			}
+			agents = append(agents, strings.ToLower(value))
+		case "crawl-delay":
+			inRules = true
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err != nil || seconds < 0 {
+				continue
+			}
+			delay := time.Duration(seconds * float64(time.Second))
+			own, star := matchGroup(agents, token)
+			if own && matched < 0 {
+				matched = delay
+			}
+			if star && wildcard < 0 {
+				wildcard = delay
+			}
+		default:
+			inRules = true
+		}
+	}
+
+	if matched >= 0 {
+		return matched
+	}
+	if wildcard >= 0 {
+		return wildcard
+	}
+	return 0
+}
+
+// productToken returns the lowercase name of a User-Agent, like
+// "cliquefarmabot" for "cliquefarmabot v1.0.0" or "cliquefarmabot/1.0".
+func productToken(userAgent string) string {
+	fields := strings.Fields(userAgent)
+	if len(fields) == 0 {
+		return ""
+	}
+	token, _, _ := strings.Cut(fields[0], "/")
+	return strings.ToLower(token)
+}
+
+// matchGroup reports whether the user-agent lines of a group name token,
+// and whether they include "*".
+func matchGroup(agents []string, token string) (own bool, star bool) {
+	for _, agent := range agents {
+		switch {
+		case agent == "*":
+			star = true
+		case token != "" && strings.Contains(token, agent):
+			own = true
+		}
+	}
+	return own, star
+}
@@ -0,0 +1,249 @@
+package report
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/result"
+)
+
+// topSize is the number of hosts and URLs listed in the top tables.
+const topSize = 20
+
+//go:embed report.html.tmpl
+var reportTemplate string
+
+var page = template.Must(template.New("report").Funcs(template.FuncMap{
+	"percent": func(count, total int) string {
+		if total == 0 {
+			return "0%"
+		}
+		return fmt.Sprintf("%.1f%%", float64(count)*100/float64(total))
+	},
+}).Parse(reportTemplate))
+
+// Collector is a result.Writer keeping every result of a run for the report.
+type Collector struct {
+	mu      sync.Mutex
+	results []result.Result
+}
+
+// NewCollector returns an empty collector.
+func NewCollector() *Collector {
+	return &Collector{}
+}
+
+// Write keeps r.
+func (c *Collector) Write(r result.Result) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.results = append(c.results, r)
+	return nil
+}
+
+// Close does nothing, the report is written by WriteHTML.
+func (c *Collector) Close() error {
+	return nil
+}
+
+// Options are the details of the run shown in the report header.
+type Options struct {
+	Title       string
+	Input       string
+	GeneratedAt time.Time
+}
+
+// Count is a number of results under a name.
+type Count struct {
+	Name  string
+	Count int
+}
+
+// Group counts the results of a Departamento/Categoria by status.
+type Group struct {
+	Departamento string
+	Categoria    string
+	Total        int
+	ByStatus     map[string]int
+}
+
+// SlowURL is a probed URL and how long it took.
+type SlowURL struct {
+	URL        string
+	Status     string
+	DurationMs int64
+	Attempts   int
+}
+
+// Row is a line of the filterable table.
+type Row struct {
+	Sku          string `json:"sku"`
+	From         string `json:"from"`
+	To           string `json:"to"`
+	Status       string `json:"status"`
+	FromStatus   string `json:"fromStatus"`
+	ToStatus     string `json:"toStatus"`
+	Departamento string `json:"departamento"`
+	Categoria    string `json:"categoria"`
+	Explanation  string `json:"explanation"`
+}
+
+// Data is everything the report shows.
+type Data struct {
+	Options
+	Total        int
+	Statuses     []string
+	ByStatus     []Count
+	Groups       []Group
+	FailingHosts []Count
+	SlowestURLs  []SlowURL
+	Rows         []Row
+}
+
+// Data summarizes the collected results.
+func (c *Collector) Data(opts Options) Data {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data := Data{Options: opts, Total: len(c.results), Rows: make([]Row, 0, len(c.results))}
+	byStatus := map[string]int{}
+	groups := map[[2]string]*Group{}
+	failing := map[string]int{}
+	var probes []SlowURL
+	// seen are the URLs already counted, a Para shared by many rows being
+	// counted once.
+	seen := map[string]bool{}
+
+	for _, r := range c.results {
+		byStatus[r.Status]++
+
+		key := [2]string{r.Departamento, r.Categoria}
+		group, ok := groups[key]
+		if !ok {
+			group = &Group{Departamento: r.Departamento, Categoria: r.Categoria, ByStatus: map[string]int{}}
+			groups[key] = group
+		}
+		group.Total++
+		group.ByStatus[r.Status]++
+
+		for _, p := range []*result.Probe{r.FromProbe, r.ToProbe} {
+			if p == nil || seen[p.URL] {
+				continue
+			}
+			seen[p.URL] = true
+			if failed(p) {
+				failing[host(p.URL)]++
+			}
+			probes = append(probes, SlowURL{URL: p.URL, Status: p.FinalStatus, DurationMs: p.DurationMs, Attempts: p.Attempts})
+		}
+
+		data.Rows = append(data.Rows, Row{
+			Sku:          r.Sku,
+			From:         r.From,
+			To:           r.To,
+			Status:       r.Status,
+			FromStatus:   finalStatus(r.FromProbe),
+			ToStatus:     finalStatus(r.ToProbe),
+			Departamento: r.Departamento,
+			Categoria:    r.Categoria,
+			Explanation:  r.Explanation,
+		})
+	}
+
+	data.ByStatus = sortedCounts(byStatus)
+	for _, c := range data.ByStatus {
+		data.Statuses = append(data.Statuses, c.Name)
+	}
+	sort.Strings(data.Statuses)
+
+	for _, group := range groups {
+		data.Groups = append(data.Groups, *group)
+	}
+	sort.Slice(data.Groups, func(i, j int) bool {
+		a, b := data.Groups[i], data.Groups[j]
+		if a.Departamento != b.Departamento {
+			return a.Departamento < b.Departamento
+		}
+		return a.Categoria < b.Categoria
+	})
+
+	data.FailingHosts = top(sortedCounts(failing))
+
+	sort.SliceStable(probes, func(i, j int) bool {
+		return probes[i].DurationMs > probes[j].DurationMs
+	})
+	if len(probes) > topSize {
+		probes = probes[:topSize]
+	}
+	data.SlowestURLs = probes
+
+	return data
+}
+
+// WriteHTML writes the report as a single HTML file working offline.
+func (c *Collector) WriteHTML(w io.Writer, opts Options) error {
+	if opts.Title == "" {
+		opts.Title = "Redirect analysis"
+	}
+	if opts.GeneratedAt.IsZero() {
+		opts.GeneratedAt = time.Now()
+	}
+	if err := page.Execute(w, c.Data(opts)); err != nil {
+		return fmt.Errorf("report: could not write html: %w", err)
+	}
+	return nil
+}
+
+// failed reports whether a URL got no response or a server error.
+func failed(p *result.Probe) bool {
+	if p.Failure != "" {
+		return true
+	}
+	code, err := strconv.Atoi(p.FinalStatus)
+	return err == nil && code >= 500
+}
+
+func finalStatus(p *result.Probe) string {
+	if p == nil {
+		return ""
+	}
+	return p.FinalStatus
+}
+
+func host(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	return strings.ToLower(u.Host)
+}
+
+// sortedCounts returns counts, highest first, then by name.
+func sortedCounts(counts map[string]int) []Count {
+	sorted := make([]Count, 0, len(counts))
+	for name, count := range counts {
+		sorted = append(sorted, Count{Name: name, Count: count})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Count != sorted[j].Count {
+			return sorted[i].Count > sorted[j].Count
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+func top(counts []Count) []Count {
+	if len(counts) > topSize {
+		return counts[:topSize]
+	}
+	return counts
+}
@@ -0,0 +1,97 @@
+package report_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/report"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/result"
+	"github.com/stretchr/testify/require"
+)
+
+func newCollector(t *testing.T) *report.Collector {
+	t.Helper()
+	collector := report.NewCollector()
+	for _, r := range []result.Result{
+		{
+			Sku: "1", Status: "REDIRECIONAR", Departamento: "Medicamentos", Categoria: "Dor",
+			FromProbe: &result.Probe{URL: "https://www.cliquefarma.com.br/a", FinalStatus: "200", DurationMs: 120},
+			ToProbe:   &result.Probe{URL: "https://www.cliquefarma.com.br/b", FinalStatus: "404", DurationMs: 40},
+		},
+		{
+			Sku: "2", Status: "ERRO", Departamento: "Medicamentos", Categoria: "Dor",
+			FromProbe: &result.Probe{URL: "https://static.cliquefarma.com.br/c", Failure: "TIMEOUT", FinalStatus: "TIMEOUT", DurationMs: 3000, Attempts: 3},
+			ToProbe:   &result.Probe{URL: "https://www.cliquefarma.com.br/d", FinalStatus: "503", DurationMs: 10},
+		},
+		{
+			Sku: "3", Status: "REDIRECIONAR", Departamento: "Beleza", Categoria: "Cabelo",
+			Explanation: "</script><script>alert(1)</script>",
+			FromProbe:   &result.Probe{URL: "https://www.cliquefarma.com.br/e", FinalStatus: "200", DurationMs: 80},
+			ToProbe:     &result.Probe{URL: "https://www.cliquefarma.com.br/f", FinalStatus: "404", DurationMs: 70},
+		},
+	} {
+		require.NoError(t, collector.Write(r))
+	}
+	return collector
+}
+
+func TestData(t *testing.T) {
+	data := newCollector(t).Data(report.Options{})
+
+	require.Equal(t, 3, data.Total)
+	require.Equal(t, []report.Count{{Name: "REDIRECIONAR", Count: 2}, {Name: "ERRO", Count: 1}}, data.ByStatus)
+	require.Equal(t, []string{"ERRO", "REDIRECIONAR"}, data.Statuses)
+
+	require.Len(t, data.Groups, 2)
+	require.Equal(t, "Beleza", data.Groups[0].Departamento)
+	require.Equal(t, 2, data.Groups[1].Total)
+	require.Equal(t, map[string]int{"REDIRECIONAR": 1, "ERRO": 1}, data.Groups[1].ByStatus)
+
+	require.Equal(t, []report.Count{
+		{Name: "static.cliquefarma.com.br", Count: 1},
+		{Name: "www.cliquefarma.com.br", Count: 1},
+	}, data.FailingHosts)
+
+	require.Len(t, data.SlowestURLs, 6)
+	require.Equal(t, "https://static.cliquefarma.com.br/c", data.SlowestURLs[0].URL)
+	require.Equal(t, 3, data.SlowestURLs[0].Attempts)
+	require.Len(t, data.Rows, 3)
+}
+
+func TestDataSharedPara(t *testing.T) {
+	collector := report.NewCollector()
+	para := &result.Probe{URL: "https://www.cliquefarma.com.br/b", FinalStatus: "503", DurationMs: 900}
+	for _, sku := range []string{"1", "2"} {
+		require.NoError(t, collector.Write(result.Result{
+			Sku: sku, Status: "ALTERAR",
+			FromProbe: &result.Probe{URL: "https://www.cliquefarma.com.br/a" + sku, FinalStatus: "404", DurationMs: 10},
+			ToProbe:   para,
+		}))
+	}
+	data := collector.Data(report.Options{})
+
+	require.Equal(t, []report.Count{{Name: "www.cliquefarma.com.br", Count: 1}}, data.FailingHosts)
+	require.Len(t, data.SlowestURLs, 3)
+	require.Equal(t, "https://www.cliquefarma.com.br/b", data.SlowestURLs[0].URL)
+	require.NotEqual(t, data.SlowestURLs[0].URL, data.SlowestURLs[1].URL)
+}
+
+func TestWriteHTML(t *testing.T) {
+	var buf bytes.Buffer
+	err := newCollector(t).WriteHTML(&buf, report.Options{
+		Input:       "products.csv",
+		GeneratedAt: time.Date(2024, 5, 2, 13, 4, 5, 0, time.UTC),
+	})
+	require.NoError(t, err)
+
+	html := buf.String()
+	require.Contains(t, html, "<title>Redirect analysis</title>")
+	require.Contains(t, html, "products.csv &middot; 3 pairs &middot; generated 2024-05-02 13:04:05")
+	require.Contains(t, html, "66.7%")
+	require.Contains(t, html, "static.cliquefarma.com.br")
+	// Rows are embedded as data and must not close the script holding them.
+	require.NotContains(t, html, "</script><script>alert(1)")
+	require.NotContains(t, html, "<link")
+	require.NotContains(t, html, "<script src")
+}
@@ -0,0 +1,291 @@
+package result
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format is a file format results can be written in.
+type Format string
+
+const (
+	// CSV writes one row per result, with the columns given to the writer.
+	CSV Format = "csv"
+	// JSON writes a single array holding every result.
+	JSON Format = "json"
+	// NDJSON writes one JSON object per line, as results come.
+	NDJSON Format = "ndjson"
+)
+
+// Formats lists every supported format.
+func Formats() []Format {
+	return []Format{CSV, JSON, NDJSON}
+}
+
+// ErrNotAppendable is returned when asking to append to a JSON array.
+var ErrNotAppendable = errors.New("result: a json array can not be appended to, use ndjson to resume")
+
+// FormatFor returns format, or the one matching the extension of path when
+// format is empty. Unknown extensions are written as CSV.
+func FormatFor(path string, format Format) (Format, error) {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".json":
+			return JSON, nil
+		case ".ndjson", ".jsonl":
+			return NDJSON, nil
+		}
+		return CSV, nil
+	}
+	for _, f := range Formats() {
+		if f == format {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("result: unknown format %q, expected one of %q", format, Formats())
+}
+
+// Result is everything learned about a De/Para pair.
+type Result struct {
+	Sku  string `json:"sku"`
+	From string `json:"from"`
+	To   string `json:"to"`
+	// FromDerived and ToDerived are set when the URL was built by the URL
+	// template instead of read from the input.
+	FromDerived bool `json:"fromDerived,omitempty"`
+	ToDerived   bool `json:"toDerived,omitempty"`
+	// Similarity is how alike the products of De and Para are, from 0 to 1,
+	// when both answered 200.
+	Similarity   *float64  `json:"similarity,omitempty"`
+	Status       string    `json:"status"`
+	Explanation  string    `json:"explanation,omitempty"`
+	OldSlug      string    `json:"oldSlug,omitempty"`
+	NewSlug      string    `json:"newSlug,omitempty"`
+	Departamento string    `json:"departamento,omitempty"`
+	Categoria    string    `json:"categoria,omitempty"`
+	FromProbe    *Probe    `json:"fromProbe,omitempty"`
+	ToProbe      *Probe    `json:"toProbe,omitempty"`
+	CheckedAt    time.Time `json:"checkedAt"`
+}
+
+// Probe is what a URL answered, hop by hop.
+type Probe struct {
+	URL         string `json:"url"`
+	Method      string `json:"method,omitempty"`
+	Status      string `json:"status"`
+	FinalURL    string `json:"finalUrl"`
+	FinalStatus string `json:"finalStatus"`
+	Redirects   int    `json:"redirects"`
+	Hops        []Hop  `json:"hops"`
+	// Failure is the code of a request that got no response, like TIMEOUT.
+	Failure string `json:"failure,omitempty"`
+	Soft404 string `json:"soft404,omitempty"`
+	// Canonical, Robots and Hreflang are the indexing hints of the end of
+	// the chain.
+	Canonical string      `json:"canonical,omitempty"`
+	Robots    []string    `json:"robots,omitempty"`
+	Hreflang  []Alternate `json:"hreflang,omitempty"`
+	// Page is the product shown at the end of the chain.
+	Page       *Page  `json:"page,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Attempts   int    `json:"attempts"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// Chain formats the hops as "301 https://a -> 200 https://b".
+func (p *Probe) Chain() string {
+	if p == nil {
+		return ""
+	}
+	parts := make([]string, 0, len(p.Hops))
+	for _, hop := range p.Hops {
+		parts = append(parts, strconv.Itoa(hop.StatusCode)+" "+hop.URL)
+	}
+	return strings.Join(parts, " -> ")
+}
+
+// Hop is one response of a redirect chain.
+type Hop struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"statusCode"`
+	Location   string `json:"location,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// Alternate is a hreflang alternate of a page.
+type Alternate struct {
+	Lang string `json:"lang"`
+	URL  string `json:"url"`
+}
+
+// Page is what a page tells about the product it shows.
+type Page struct {
+	Title string  `json:"title,omitempty"`
+	H1    string  `json:"h1,omitempty"`
+	Name  string  `json:"name,omitempty"`
+	Sku   string  `json:"sku,omitempty"`
+	Price float64 `json:"price,omitempty"`
+	Kind  string  `json:"kind,omitempty"`
+}
+
+// Column is a CSV column and how its value is read from a result.
+type Column struct {
+	Name  string
+	Value func(Result) string
+}
+
+// Header returns the names of columns.
+func Header(columns []Column) []string {
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = c.Name
+	}
+	return header
+}
+
+// Writer writes results in a format. Write flushes every result, so what
+// was written survives a crash, and Close terminates the document.
+type Writer interface {
+	Write(Result) error
+	Close() error
+}
+
+// NewWriter returns a writer of format over w. columns are the ones of CSV
+// rows. When appending, w already holds results: the CSV header is not
+// written again, and JSON arrays are refused.
+func NewWriter(w io.Writer, format Format, columns []Column, appending bool) (Writer, error) {
+	switch format {
+	case CSV:
+		writer := &csvWriter{writer: csv.NewWriter(w), columns: columns}
+		if !appending {
+			if err := writer.writeRow(Header(columns)); err != nil {
+				return nil, err
+			}
+		}
+		return writer, nil
+	case JSON:
+		if appending {
+			return nil, ErrNotAppendable
+		}
+		writer := &jsonWriter{writer: bufio.NewWriter(w)}
+		if err := writer.flush("["); err != nil {
+			return nil, err
+		}
+		return writer, nil
+	case NDJSON:
+		return &ndjsonWriter{writer: bufio.NewWriter(w)}, nil
+	}
+	return nil, fmt.Errorf("result: unknown format %q, expected one of %q", format, Formats())
+}
+
+// MultiWriter returns a writer writing every result to each of writers.
+func MultiWriter(writers ...Writer) Writer {
+	return multiWriter(writers)
+}
+
+type multiWriter []Writer
+
+func (m multiWriter) Write(r Result) error {
+	for _, w := range m {
+		if err := w.Write(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m multiWriter) Close() error {
+	var first error
+	for _, w := range m {
+		if err := w.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+type csvWriter struct {
+	writer  *csv.Writer
+	columns []Column
+}
+
+func (w *csvWriter) Write(r Result) error {
+	row := make([]string, len(w.columns))
+	for i, c := range w.columns {
+		row[i] = c.Value(r)
+	}
+	return w.writeRow(row)
+}
+
+func (w *csvWriter) writeRow(row []string) error {
+	if err := w.writer.Write(row); err != nil {
+		return fmt.Errorf("result: could not write csv row: %w", err)
+	}
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+func (w *csvWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+type jsonWriter struct {
+	writer *bufio.Writer
+	count  int
+}
+
+func (w *jsonWriter) Write(r Result) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("result: could not encode result: %w", err)
+	}
+	separator := ",\n"
+	if w.count == 0 {
+		separator = "\n"
+	}
+	w.count++
+	return w.flush(separator + string(data))
+}
+
+func (w *jsonWriter) Close() error {
+	end := "\n]\n"
+	if w.count == 0 {
+		end = "]\n"
+	}
+	return w.flush(end)
+}
+
+func (w *jsonWriter) flush(s string) error {
+	if _, err := w.writer.WriteString(s); err != nil {
+		return fmt.Errorf("result: could not write json: %w", err)
+	}
+	return w.writer.Flush()
+}
+
+type ndjsonWriter struct {
+	writer *bufio.Writer
+}
+
+func (w *ndjsonWriter) Write(r Result) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("result: could not encode result: %w", err)
+	}
+	if _, err := w.writer.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("result: could not write ndjson: %w", err)
+	}
+	return w.writer.Flush()
+}
+
+func (w *ndjsonWriter) Close() error {
+	return w.writer.Flush()
+}
@@ -0,0 +1,133 @@
+package result_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/result"
+	"github.com/stretchr/testify/require"
+)
+
+var testColumns = []result.Column{
+	{Name: "Sku", Value: func(r result.Result) string { return r.Sku }},
+	{Name: "Status", Value: func(r result.Result) string { return r.Status }},
+	{Name: "De Chain", Value: func(r result.Result) string { return r.FromProbe.Chain() }},
+}
+
+func testResults() []result.Result {
+	return []result.Result{
+		{
+			Sku: "1", From: "https://a/old", To: "https://a/new", Status: "REDIRECIONAR",
+			FromProbe: &result.Probe{
+				URL: "https://a/old", Status: "301", FinalURL: "https://a/new", FinalStatus: "200", Redirects: 1,
+				Hops: []result.Hop{
+					{URL: "https://a/old", StatusCode: 301, Location: "https://a/new"},
+					{URL: "https://a/new", StatusCode: 200},
+				},
+			},
+		},
+		{Sku: "2", Status: "ERRO", FromProbe: &result.Probe{Status: "TIMEOUT", Failure: "TIMEOUT"}},
+	}
+}
+
+func TestWriter(t *testing.T) {
+	testCases := []struct {
+		desc string
+
+		format    result.Format
+		appending bool
+		results   []result.Result
+		expected  string
+	}{
+		{
+			desc:     "csv",
+			format:   result.CSV,
+			results:  testResults(),
+			expected: "Sku,Status,De Chain\n1,REDIRECIONAR,301 https://a/old -> 200 https://a/new\n2,ERRO,\n",
+		},
+		{
+			desc:      "csv appended without header",
+			format:    result.CSV,
+			appending: true,
+			results:   testResults()[1:],
+			expected:  "2,ERRO,\n",
+		},
+		{
+			desc:     "empty json array",
+			format:   result.JSON,
+			expected: "[]\n",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			var buf bytes.Buffer
+			writer, err := result.NewWriter(&buf, tC.format, testColumns, tC.appending)
+			require.NoError(t, err)
+			for _, r := range tC.results {
+				require.NoError(t, writer.Write(r))
+			}
+			require.NoError(t, writer.Close())
+			require.Equal(t, tC.expected, buf.String())
+		})
+	}
+}
+
+func TestWriterJSON(t *testing.T) {
+	for _, format := range []result.Format{result.JSON, result.NDJSON} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			writer, err := result.NewWriter(&buf, format, testColumns, false)
+			require.NoError(t, err)
+			for _, r := range testResults() {
+				require.NoError(t, writer.Write(r))
+			}
+			require.NoError(t, writer.Close())
+
+			var decoded []result.Result
+			if format == result.JSON {
+				require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+			} else {
+				lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+				require.Len(t, lines, 2)
+				for _, line := range lines {
+					var r result.Result
+					require.NoError(t, json.Unmarshal([]byte(line), &r))
+					decoded = append(decoded, r)
+				}
+			}
+			require.Equal(t, testResults(), decoded)
+		})
+	}
+}
+
+func TestNewWriterRefusesAppendingJSON(t *testing.T) {
+	_, err := result.NewWriter(&bytes.Buffer{}, result.JSON, nil, true)
+	require.ErrorIs(t, err, result.ErrNotAppendable)
+}
+
+func TestFormatFor(t *testing.T) {
+	testCases := []struct {
+		desc string
+
+		path             string
+		format           result.Format
+		expected         result.Format
+		errAssertionFunc require.ErrorAssertionFunc
+	}{
+		{desc: "csv extension", path: "output.csv", expected: result.CSV, errAssertionFunc: require.NoError},
+		{desc: "json extension", path: "output.JSON", expected: result.JSON, errAssertionFunc: require.NoError},
+		{desc: "jsonl extension", path: "output.jsonl", expected: result.NDJSON, errAssertionFunc: require.NoError},
+		{desc: "unknown extension", path: "output.txt", expected: result.CSV, errAssertionFunc: require.NoError},
+		{desc: "explicit format", path: "output.csv", format: result.NDJSON, expected: result.NDJSON, errAssertionFunc: require.NoError},
+		{desc: "unknown format", path: "output.csv", format: "xml", errAssertionFunc: require.Error},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			format, err := result.FormatFor(tC.path, tC.format)
+			tC.errAssertionFunc(t, err)
+			require.Equal(t, tC.expected, format)
+		})
+	}
+}
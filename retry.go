@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/config"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/metadata"
+)
+
+// Failures written in place of a status code when a request got no response.
+const (
+	FailureTimeout = "TIMEOUT"
+	FailureDNS     = "DNS_ERROR"
+	FailureTLS     = "TLS_ERROR"
+	FailureRefused = "CONNECTION_REFUSED"
+	FailureReset   = "CONNECTION_RESET"
+	FailureOther   = "REQUEST_ERROR"
+)
+
+// retryPolicy is how often and how long a hop is retried, read from the
+// "retries", "retryBackoff" and "retryMaxBackoff" options.
+type retryPolicy struct {
+	retries    int
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+func newRetryPolicy(meta metadata.Map) retryPolicy {
+	p := retryPolicy{
+		retries:    meta.AsInt("retries", 0),
+		backoff:    meta.AsDuration("retryBackoff", config.DefaultRetryBackoff),
+		maxBackoff: meta.AsDuration("retryMaxBackoff", config.DefaultRetryMaxBackoff),
+	}
+	if p.maxBackoff < p.backoff {
+		p.maxBackoff = p.backoff
+	}
+	return p
+}
+
+// classifyError returns the failure code of a request error.
+func classifyError(err error) string {
+	var (
+		dnsErr      *net.DNSError
+		netErr      net.Error
+		unknownAuth x509.UnknownAuthorityError
+		hostnameErr x509.HostnameError
+		invalidCert x509.CertificateInvalidError
+	)
+
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &dnsErr):
+		if dnsErr.IsTimeout {
+			return FailureTimeout
+		}
+		return FailureDNS
+	case errors.As(err, &unknownAuth), errors.As(err, &hostnameErr), errors.As(err, &invalidCert),
+		strings.Contains(err.Error(), "tls: "), strings.Contains(err.Error(), "x509: "):
+		return FailureTLS
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return FailureTimeout
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return FailureRefused
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE),
+		errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return FailureReset
+	}
+	return FailureOther
+}
+
+// retryable reports whether a hop is worth sending again: it timed out, the
+// connection was reset, or the server asked to slow down with 429 or 503.
+func retryable(res *http.Response, err error) bool {
+	if err != nil {
+		switch classifyError(err) {
+		case FailureTimeout, FailureReset:
+			return true
+		}
+		return false
+	}
+	return res.StatusCode == http.StatusTooManyRequests || res.StatusCode == http.StatusServiceUnavailable
+}
+
+// wait returns how long to wait before the retry following attempt, counted
+// from 0. A Retry-After header is honored up to maxBackoff; otherwise the
+// wait is a random duration up to an exponential backoff ("full jitter").
+func (p retryPolicy) wait(attempt int, res *http.Response) time.Duration {
+	if res != nil {
+		if after, ok := retryAfter(res.Header.Get("Retry-After"), time.Now()); ok {
+			if after > p.maxBackoff {
+				return p.maxBackoff
+			}
+			return after
+		}
+	}
+
+	ceiling := p.backoff
+	for i := 0; i < attempt && ceiling < p.maxBackoff; i++ {
+		ceiling *= 2
+	}
+	if ceiling > p.maxBackoff {
+		ceiling = p.maxBackoff
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// retryAfter parses a Retry-After header, given in seconds or as an HTTP date.
+func retryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if after := date.Sub(now); after > 0 {
+		return after, true
+	}
+	return 0, true
+}
+
+// sleep waits for d, returning early with the error of ctx when it is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,378 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Unclassified is the outcome of a pair no rule matched.
+const Unclassified = "NAO_CLASSIFICADO"
+
+// DefaultMaxSimilarity is the similarity under which the default rules take
+// Para for another product than De.
+const DefaultMaxSimilarity = 0.5
+
+// kinds are the page kinds a rule can ask for, empty for a page telling
+// neither.
+var kinds = []string{"product", "category", ""}
+
+// failurePattern matches a URL that got no response, whatever the reason.
+const failurePattern = "FAILURE"
+
+// Rule names the outcome of the pairs matching all of its conditions.
+// Conditions left empty match anything, so a rule without any is a catch-all.
+type Rule struct {
+	Name string `yaml:"name"`
+	// Explanation is written to the output next to the outcome. It may use
+	// {from.status}, {from.finalStatus}, {from.hops}, {from.finalURL},
+	// {from.soft404}, {from.canonical}, {from.robots}, {from.kind}, the same
+	// placeholders for to, and {similarity}.
+	Explanation string `yaml:"explanation"`
+	From        URL    `yaml:"from"`
+	To          URL    `yaml:"to"`
+	// FromReachesTo requires the redirect chain of De to end, or not, on Para.
+	FromReachesTo *bool `yaml:"fromReachesTo"`
+	// MaxSimilarity requires the products of De and Para, both answering
+	// 200, to be at most this alike, from 0 to 1.
+	MaxSimilarity *float64 `yaml:"maxSimilarity"`
+}
+
+// URL holds the conditions on the probe of a De or Para URL.
+type URL struct {
+	// Status and FinalStatus list the accepted statuses of the URL itself and
+	// of the end of its chain: a code ("404"), a class ("5xx"), a failure code
+	// ("TIMEOUT") or FAILURE for any request that got no response.
+	Status      []string `yaml:"status"`
+	FinalStatus []string `yaml:"finalStatus"`
+	MinHops     *int     `yaml:"minHops"`
+	MaxHops     *int     `yaml:"maxHops"`
+	// BodyContains and BodyNotContains are compared ignoring case to the body
+	// of the end of the chain, BodyMatches is a regular expression.
+	BodyContains    []string `yaml:"bodyContains"`
+	BodyNotContains []string `yaml:"bodyNotContains"`
+	BodyMatches     string   `yaml:"bodyMatches"`
+	// CanonicalElsewhere requires the canonical link of the end of the chain
+	// to point, or not, to another URL. Noindex requires it to ask, or not,
+	// not to be indexed, by meta robots or X-Robots-Tag.
+	CanonicalElsewhere *bool `yaml:"canonicalElsewhere"`
+	Noindex            *bool `yaml:"noindex"`
+	// Kind lists the accepted kinds of the page at the end of the chain:
+	// "product", "category" or "" when the page tells neither.
+	Kind []string `yaml:"kind"`
+}
+
+// Probe is what the classification knows about a De or Para URL.
+type Probe struct {
+	// Status and FinalStatus are status codes, or failure codes like TIMEOUT.
+	Status      string
+	FinalStatus string
+	Hops        int
+	FinalURL    string
+	Body        string
+	// Soft404 is why a page answering 200 was taken as missing.
+	Soft404 string
+	// Canonical is the canonical link of the page, CanonicalElsewhere
+	// telling it is another URL.
+	Canonical          string
+	CanonicalElsewhere bool
+	// Robots are the robots directives of the page, Noindex telling they
+	// ask not to index it.
+	Robots  string
+	Noindex bool
+	// Kind is "product", "category" or empty, told by the page markup.
+	Kind string
+}
+
+// Facts are the probes of a De/Para pair.
+type Facts struct {
+	From Probe
+	To   Probe
+	// FromReachesTo tells whether the chain of De ends on Para.
+	FromReachesTo bool
+	// Similarity is how alike the products of De and Para are, from 0 to 1,
+	// when Compared tells both answered 200 with something to compare.
+	Similarity float64
+	Compared   bool
+}
+
+// Default returns the rules of the original classification: Para answering
+// 200 is to be analyzed, De answering 200 is to be redirected, anything else
+// needs its slug changed. Pairs with a URL that got no response are errors,
+// and a Para not to be indexed, with its canonical elsewhere, being a
+// category page or another product gets its own outcome.
+func Default() []Rule {
+	yes := true
+	maxSimilarity := DefaultMaxSimilarity
+	return []Rule{
+		{
+			Name:        "ERRO",
+			Explanation: "no response: De {from.finalStatus}, Para {to.finalStatus}",
+			From:        URL{FinalStatus: []string{failurePattern}},
+		},
+		{
+			Name:        "ERRO",
+			Explanation: "no response: De {from.finalStatus}, Para {to.finalStatus}",
+			To:          URL{FinalStatus: []string{failurePattern}},
+		},
+		{
+			Name:        "NOINDEX",
+			Explanation: "Para answers {to.finalStatus} but is not to be indexed: {to.robots}",
+			To:          URL{FinalStatus: []string{"200"}, Noindex: &yes},
+		},
+		{
+			Name:        "CANONICAL_DIVERGENTE",
+			Explanation: "Para answers {to.finalStatus} but its canonical is {to.canonical}",
+			To:          URL{FinalStatus: []string{"200"}, CanonicalElsewhere: &yes},
+		},
+		{
+			Name:        "PARA_CATEGORIA",
+			Explanation: "Para answers {to.finalStatus} but is a category page",
+			To:          URL{FinalStatus: []string{"200"}, Kind: []string{"category"}},
+		},
+		{
+			Name:          "PRODUTO_DIFERENTE",
+			Explanation:   "Para answers {to.finalStatus} but its product is {similarity} alike to the one of De",
+			MaxSimilarity: &maxSimilarity,
+		},
+		{
+			Name:        "ANALISAR",
+			Explanation: "Para answers {to.finalStatus}",
+			To:          URL{FinalStatus: []string{"200"}},
+		},
+		{
+			Name:        "REDIRECIONAR",
+			Explanation: "De answers {from.finalStatus} and Para {to.finalStatus}",
+			From:        URL{FinalStatus: []string{"200"}},
+		},
+		{
+			Name:        "ALTERAR",
+			Explanation: "De answers {from.finalStatus} and Para {to.finalStatus}",
+		},
+	}
+}
+
+// Engine classifies pairs with the first matching rule.
+type Engine struct {
+	rules     []compiled
+	needsBody bool
+	needsPage bool
+}
+
+type compiled struct {
+	Rule
+	from, to urlMatcher
+}
+
+type urlMatcher struct {
+	URL
+	bodyMatches *regexp.Regexp
+}
+
+// New checks and compiles rules, kept in order.
+func New(rules []Rule) (*Engine, error) {
+	if len(rules) == 0 {
+		return nil, errors.New("rules: at least one rule is required")
+	}
+
+	engine := &Engine{rules: make([]compiled, 0, len(rules))}
+	for i, rule := range rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("rules: rule %d has no name", i+1)
+		}
+		from, err := compileURL(rule.From)
+		if err != nil {
+			return nil, fmt.Errorf("rules: rule %d (%s) from: %w", i+1, rule.Name, err)
+		}
+		to, err := compileURL(rule.To)
+		if err != nil {
+			return nil, fmt.Errorf("rules: rule %d (%s) to: %w", i+1, rule.Name, err)
+		}
+		if rule.MaxSimilarity != nil && (*rule.MaxSimilarity < 0 || *rule.MaxSimilarity > 1) {
+			return nil, fmt.Errorf("rules: rule %d (%s) maxSimilarity must be from 0 to 1, got %v", i+1, rule.Name, *rule.MaxSimilarity)
+		}
+		engine.rules = append(engine.rules, compiled{Rule: rule, from: from, to: to})
+		engine.needsBody = engine.needsBody || from.usesBody() || to.usesBody()
+		engine.needsPage = engine.needsPage || from.usesPage() || to.usesPage() || rule.MaxSimilarity != nil
+	}
+	return engine, nil
+}
+
+func compileURL(u URL) (urlMatcher, error) {
+	m := urlMatcher{URL: u}
+	for _, pattern := range append(append([]string{}, u.Status...), u.FinalStatus...) {
+		if !validStatusPattern(pattern) {
+			return m, fmt.Errorf("invalid status %q, expected a code like 404, a class like 4xx or a failure code", pattern)
+		}
+	}
+	for _, kind := range u.Kind {
+		if !contains(kinds, kind) {
+			return m, fmt.Errorf("invalid kind %q, expected one of %q", kind, kinds)
+		}
+	}
+	if u.BodyMatches != "" {
+		re, err := regexp.Compile(u.BodyMatches)
+		if err != nil {
+			return m, fmt.Errorf("invalid bodyMatches: %w", err)
+		}
+		m.bodyMatches = re
+	}
+	return m, nil
+}
+
+// NeedsBody reports whether a rule looks at the bodies, which are not
+// read otherwise.
+func (e *Engine) NeedsBody() bool {
+	return e.needsBody
+}
+
+// NeedsPage reports whether a rule looks at what is parsed from the bodies:
+// canonical link, robots directives, page kind or similarity.
+func (e *Engine) NeedsPage() bool {
+	return e.needsPage
+}
+
+// Classify returns the name and explanation of the first rule matching facts,
+// or Unclassified.
+func (e *Engine) Classify(facts Facts) (string, string) {
+	for _, rule := range e.rules {
+		if rule.FromReachesTo != nil && *rule.FromReachesTo != facts.FromReachesTo {
+			continue
+		}
+		if rule.MaxSimilarity != nil && (!facts.Compared || facts.Similarity > *rule.MaxSimilarity) {
+			continue
+		}
+		if !rule.from.match(facts.From) || !rule.to.match(facts.To) {
+			continue
+		}
+		return rule.Name, explain(rule.Explanation, facts)
+	}
+	return Unclassified, "no rule matched"
+}
+
+func (m urlMatcher) usesBody() bool {
+	return len(m.BodyContains) > 0 || len(m.BodyNotContains) > 0 || m.bodyMatches != nil
+}
+
+func (m urlMatcher) usesPage() bool {
+	return m.CanonicalElsewhere != nil || m.Noindex != nil || len(m.Kind) > 0
+}
+
+func (m urlMatcher) match(p Probe) bool {
+	if len(m.Status) > 0 && !matchStatus(m.Status, p.Status) {
+		return false
+	}
+	if len(m.FinalStatus) > 0 && !matchStatus(m.FinalStatus, p.FinalStatus) {
+		return false
+	}
+	if m.MinHops != nil && p.Hops < *m.MinHops {
+		return false
+	}
+	if m.MaxHops != nil && p.Hops > *m.MaxHops {
+		return false
+	}
+	if m.CanonicalElsewhere != nil && p.CanonicalElsewhere != *m.CanonicalElsewhere {
+		return false
+	}
+	if m.Noindex != nil && p.Noindex != *m.Noindex {
+		return false
+	}
+	if len(m.Kind) > 0 && !contains(m.Kind, p.Kind) {
+		return false
+	}
+	if !m.usesBody() {
+		return true
+	}
+
+	body := strings.ToLower(p.Body)
+	for _, s := range m.BodyContains {
+		if !strings.Contains(body, strings.ToLower(s)) {
+			return false
+		}
+	}
+	for _, s := range m.BodyNotContains {
+		if strings.Contains(body, strings.ToLower(s)) {
+			return false
+		}
+	}
+	return m.bodyMatches == nil || m.bodyMatches.MatchString(p.Body)
+}
+
+func matchStatus(patterns []string, status string) bool {
+	_, err := strconv.Atoi(status)
+	failed := err != nil
+	for _, pattern := range patterns {
+		pattern = strings.ToUpper(strings.TrimSpace(pattern))
+		switch {
+		case pattern == failurePattern:
+			if failed {
+				return true
+			}
+		case len(pattern) == 3 && strings.HasSuffix(pattern, "XX"):
+			if !failed && len(status) == 3 && status[0] == pattern[0] {
+				return true
+			}
+		case pattern == status:
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func validStatusPattern(pattern string) bool {
+	pattern = strings.ToUpper(strings.TrimSpace(pattern))
+	if len(pattern) == 3 {
+		if strings.HasSuffix(pattern, "XX") {
+			return pattern[0] >= '1' && pattern[0] <= '5'
+		}
+		_, err := strconv.Atoi(pattern)
+		return err == nil
+	}
+	// Failure codes, like TIMEOUT or DNS_ERROR.
+	for _, r := range pattern {
+		if (r < 'A' || r > 'Z') && r != '_' {
+			return false
+		}
+	}
+	return pattern != ""
+}
+
+func explain(explanation string, facts Facts) string {
+	return strings.NewReplacer(
+		"{from.status}", facts.From.Status,
+		"{from.finalStatus}", facts.From.FinalStatus,
+		"{from.hops}", strconv.Itoa(facts.From.Hops),
+		"{from.finalURL}", facts.From.FinalURL,
+		"{from.soft404}", facts.From.Soft404,
+		"{from.canonical}", facts.From.Canonical,
+		"{from.robots}", facts.From.Robots,
+		"{from.kind}", facts.From.Kind,
+		"{to.status}", facts.To.Status,
+		"{to.finalStatus}", facts.To.FinalStatus,
+		"{to.hops}", strconv.Itoa(facts.To.Hops),
+		"{to.finalURL}", facts.To.FinalURL,
+		"{to.soft404}", facts.To.Soft404,
+		"{to.canonical}", facts.To.Canonical,
+		"{to.robots}", facts.To.Robots,
+		"{to.kind}", facts.To.Kind,
+		"{similarity}", similarity(facts),
+	).Replace(explanation)
+}
+
+func similarity(facts Facts) string {
+	if !facts.Compared {
+		return ""
+	}
+	return strconv.FormatFloat(facts.Similarity, 'f', 2, 64)
+}
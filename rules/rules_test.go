@@ -0,0 +1,268 @@
+package rules_test
+
+import (
+	"testing"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/rules"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefault(t *testing.T) {
+	engine, err := rules.New(rules.Default())
+	require.NoError(t, err)
+	require.False(t, engine.NeedsBody())
+	require.True(t, engine.NeedsPage())
+
+	testCases := []struct {
+		desc string
+
+		from, to    string
+		status      string
+		explanation string
+	}{
+		{desc: "para answering", from: "404", to: "200", status: "ANALISAR", explanation: "Para answers 200"},
+		{desc: "de answering", from: "200", to: "404", status: "REDIRECIONAR", explanation: "De answers 200 and Para 404"},
+		{desc: "both missing", from: "404", to: "404", status: "ALTERAR", explanation: "De answers 404 and Para 404"},
+		{desc: "de timing out", from: "TIMEOUT", to: "200", status: "ERRO", explanation: "no response: De TIMEOUT, Para 200"},
+		{desc: "para refusing", from: "200", to: "CONNECTION_REFUSED", status: "ERRO", explanation: "no response: De 200, Para CONNECTION_REFUSED"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			status, explanation := engine.Classify(rules.Facts{
+				From: rules.Probe{Status: tC.from, FinalStatus: tC.from},
+				To:   rules.Probe{Status: tC.to, FinalStatus: tC.to},
+			})
+			require.Equal(t, tC.status, status)
+			require.Equal(t, tC.explanation, explanation)
+		})
+	}
+}
+
+func TestDefaultParaIndexing(t *testing.T) {
+	engine, err := rules.New(rules.Default())
+	require.NoError(t, err)
+
+	testCases := []struct {
+		desc string
+
+		to          rules.Probe
+		status      string
+		explanation string
+	}{
+		{
+			desc:        "noindex",
+			to:          rules.Probe{Status: "200", FinalStatus: "200", Robots: "noindex, follow", Noindex: true},
+			status:      "NOINDEX",
+			explanation: "Para answers 200 but is not to be indexed: noindex, follow",
+		},
+		{
+			desc:        "canonical elsewhere",
+			to:          rules.Probe{Status: "200", FinalStatus: "200", Canonical: "https://www.cliquefarma.com.br/b", CanonicalElsewhere: true},
+			status:      "CANONICAL_DIVERGENTE",
+			explanation: "Para answers 200 but its canonical is https://www.cliquefarma.com.br/b",
+		},
+		{
+			desc:        "canonical to itself",
+			to:          rules.Probe{Status: "200", FinalStatus: "200", Canonical: "https://www.cliquefarma.com.br/a"},
+			status:      "ANALISAR",
+			explanation: "Para answers 200",
+		},
+		{
+			desc:        "category page",
+			to:          rules.Probe{Status: "200", FinalStatus: "200", Kind: "category"},
+			status:      "PARA_CATEGORIA",
+			explanation: "Para answers 200 but is a category page",
+		},
+		{
+			desc:        "noindex missing page",
+			to:          rules.Probe{Status: "404", FinalStatus: "404", Noindex: true},
+			status:      "REDIRECIONAR",
+			explanation: "De answers 200 and Para 404",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			status, explanation := engine.Classify(rules.Facts{
+				From: rules.Probe{Status: "200", FinalStatus: "200"},
+				To:   tC.to,
+			})
+			require.Equal(t, tC.status, status)
+			require.Equal(t, tC.explanation, explanation)
+		})
+	}
+}
+
+func TestDefaultSimilarity(t *testing.T) {
+	engine, err := rules.New(rules.Default())
+	require.NoError(t, err)
+
+	testCases := []struct {
+		desc string
+
+		similarity  float64
+		compared    bool
+		status      string
+		explanation string
+	}{
+		{desc: "another product", similarity: 0.12, compared: true, status: "PRODUTO_DIFERENTE", explanation: "Para answers 200 but its product is 0.12 alike to the one of De"},
+		{desc: "same product", similarity: 0.9, compared: true, status: "ANALISAR", explanation: "Para answers 200"},
+		{desc: "nothing compared", status: "ANALISAR", explanation: "Para answers 200"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			status, explanation := engine.Classify(rules.Facts{
+				From:       rules.Probe{Status: "200", FinalStatus: "200"},
+				To:         rules.Probe{Status: "200", FinalStatus: "200"},
+				Similarity: tC.similarity,
+				Compared:   tC.compared,
+			})
+			require.Equal(t, tC.status, status)
+			require.Equal(t, tC.explanation, explanation)
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	yes := true
+	one := 1
+	engine, err := rules.New([]rules.Rule{
+		{
+			Name:          "JA_REDIRECIONA",
+			Explanation:   "De already redirects to Para in {from.hops} hops",
+			From:          rules.URL{Status: []string{"3xx"}},
+			FromReachesTo: &yes,
+		},
+		{
+			Name: "CADEIA",
+			From: rules.URL{MinHops: &one, FinalStatus: []string{"200"}},
+		},
+		{
+			Name: "INDISPONIVEL",
+			To:   rules.URL{FinalStatus: []string{"200"}, BodyContains: []string{"Produto indisponível"}},
+		},
+		{
+			Name: "ERRO_SERVIDOR",
+			From: rules.URL{FinalStatus: []string{"5xx", "TIMEOUT"}},
+		},
+	})
+	require.NoError(t, err)
+	require.True(t, engine.NeedsBody())
+
+	testCases := []struct {
+		desc string
+
+		facts       rules.Facts
+		status      string
+		explanation string
+	}{
+		{
+			desc: "from already redirecting to para",
+			facts: rules.Facts{
+				From:          rules.Probe{Status: "301", FinalStatus: "200", Hops: 1},
+				To:            rules.Probe{Status: "200", FinalStatus: "200"},
+				FromReachesTo: true,
+			},
+			status:      "JA_REDIRECIONA",
+			explanation: "De already redirects to Para in 1 hops",
+		},
+		{
+			desc: "from redirecting elsewhere",
+			facts: rules.Facts{
+				From: rules.Probe{Status: "301", FinalStatus: "200", Hops: 1},
+				To:   rules.Probe{Status: "404", FinalStatus: "404"},
+			},
+			status: "CADEIA",
+		},
+		{
+			desc: "body check ignoring case",
+			facts: rules.Facts{
+				From: rules.Probe{Status: "404", FinalStatus: "404"},
+				To:   rules.Probe{Status: "200", FinalStatus: "200", Body: "<h1>PRODUTO INDISPONÍVEL</h1>"},
+			},
+			status: "INDISPONIVEL",
+		},
+		{
+			desc: "status class",
+			facts: rules.Facts{
+				From: rules.Probe{Status: "503", FinalStatus: "503"},
+				To:   rules.Probe{Status: "404", FinalStatus: "404"},
+			},
+			status: "ERRO_SERVIDOR",
+		},
+		{
+			desc: "failure code",
+			facts: rules.Facts{
+				From: rules.Probe{Status: "TIMEOUT", FinalStatus: "TIMEOUT"},
+				To:   rules.Probe{Status: "404", FinalStatus: "404"},
+			},
+			status: "ERRO_SERVIDOR",
+		},
+		{
+			desc: "no rule matching",
+			facts: rules.Facts{
+				From: rules.Probe{Status: "404", FinalStatus: "404"},
+				To:   rules.Probe{Status: "404", FinalStatus: "404"},
+			},
+			status:      rules.Unclassified,
+			explanation: "no rule matched",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			status, explanation := engine.Classify(tC.facts)
+			require.Equal(t, tC.status, status)
+			require.Equal(t, tC.explanation, explanation)
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	two := 2.0
+	testCases := []struct {
+		desc string
+
+		rules            []rules.Rule
+		errAssertionFunc require.ErrorAssertionFunc
+	}{
+		{
+			desc:             "no rules",
+			errAssertionFunc: require.Error,
+		},
+		{
+			desc:             "rule without name",
+			rules:            []rules.Rule{{Explanation: "anything"}},
+			errAssertionFunc: require.Error,
+		},
+		{
+			desc:             "invalid status",
+			rules:            []rules.Rule{{Name: "X", To: rules.URL{Status: []string{"2x0"}}}},
+			errAssertionFunc: require.Error,
+		},
+		{
+			desc:             "invalid body regex",
+			rules:            []rules.Rule{{Name: "X", To: rules.URL{BodyMatches: "("}}},
+			errAssertionFunc: require.Error,
+		},
+		{
+			desc:             "invalid kind",
+			rules:            []rules.Rule{{Name: "X", To: rules.URL{Kind: []string{"home"}}}},
+			errAssertionFunc: require.Error,
+		},
+		{
+			desc:             "similarity above 1",
+			rules:            []rules.Rule{{Name: "X", MaxSimilarity: &two}},
+			errAssertionFunc: require.Error,
+		},
+		{
+			desc:             "catch-all",
+			rules:            []rules.Rule{{Name: "X"}},
+			errAssertionFunc: require.NoError,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			_, err := rules.New(tC.rules)
+			tC.errAssertionFunc(t, err)
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/columns"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/config"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/slug"
+)
+
+// runScan reads a full catalog export, finds the slugs that are unsafe in
+// URLs and writes a row redirecting each of them to its cleaned slug, in the
+// input layout, ready to analyze.
+func runScan(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("scan", flag.ContinueOnError)
+	catalog := fs.String("catalog", "", "CSV file of the full catalog export, with the Sku and the slug or URL of every product")
+	output := fs.String("output", config.DefaultInput, "CSV file to write the products with unsafe slugs to, in the input layout")
+	configPath := fs.String("config", "", "YAML config file with the column aliases, base host and url template")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *catalog == "" {
+		return fmt.Errorf("scan: -catalog is required")
+	}
+
+	cfg := config.Default()
+	if *configPath != "" {
+		if err := config.LoadFile(*configPath, &cfg); err != nil {
+			return err
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	urls, err := cfg.URLs()
+	if err != nil {
+		return err
+	}
+
+	layout, rows, err := readLayout(*catalog, cfg.Columns, columns.ParseCatalog)
+	if err != nil {
+		return err
+	}
+	if layout.URL < 0 && urls == nil {
+		return fmt.Errorf("scan: %s has no url column, set urlTemplate in the config to build the URLs", *catalog)
+	}
+
+	var records []columns.Record
+	var reasons []string
+	// slugs are the slugs of every product once cleaned, to find the
+	// cleaned slugs taken by other products.
+	var slugs []slug.Row
+	unfixable := 0
+	for _, row := range rows {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		product := layout.Record(row)
+		record, current, found := scanRecord(product)
+		if current == "" {
+			if product.URL != "" {
+				unfixable++
+				fmt.Fprintf(os.Stderr, "no slug in url %q, sku %s\n", product.URL, product.Sku)
+			}
+			continue
+		}
+		if found == nil {
+			slugs = append(slugs, slug.Row{Sku: record.Sku, OldSlug: current, NewSlug: current})
+			continue
+		}
+		if record.NewSlug == "" {
+			unfixable++
+			fmt.Fprintf(os.Stderr, "no slug can be made of %q, sku %s\n", current, record.Sku)
+			continue
+		}
+		slugs = append(slugs, slug.Row{Sku: record.Sku, OldSlug: current, NewSlug: record.NewSlug})
+		reasons = append(reasons, found...)
+
+		if urls != nil && len(record.Pairs) == 0 {
+			record.Pairs = []columns.URLPair{{N: 1}}
+			record = urls.Fill(cfg.BaseHost, record)
+		}
+		records = append(records, record)
+	}
+
+	if err := writeInputRows(*output, records); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "wrote %d of %d products to %s\n", len(records), len(rows), *output)
+	if len(reasons) > 0 {
+		fmt.Fprintf(os.Stderr, "unsafe characters: %s\n", countKinds(reasons))
+	}
+
+	collisions := 0
+	for _, issue := range slug.Check(slugs) {
+		if issue.Kind == slug.Collision {
+			collisions++
+			fmt.Fprintf(os.Stderr, "slug %s shared by skus %s\n", issue.NewSlug, issue.Sku)
+		}
+	}
+	switch {
+	case collisions > 0:
+		return fmt.Errorf("%d cleaned slugs shared by other products", collisions)
+	case unfixable > 0:
+		return fmt.Errorf("%d products without a slug to clean", unfixable)
+	}
+	return nil
+}
+
+// scanRecord returns the input row of a catalog product, its current slug,
+// read from the slug column or else the last segment of its URL, and why
+// the slug is unsafe, nil when it is not. The row redirects the URL of the
+// product, when known, to the same URL with the cleaned slug.
+func scanRecord(product columns.Record) (columns.Record, string, []string) {
+	current := product.Slug
+	// withSlug returns the URL of the product with another slug, nil when
+	// the product has no URL.
+	var withSlug func(string) string
+	if product.URL != "" {
+		if u, err := url.Parse(product.URL); err == nil {
+			withSlug = func(s string) string { return withLastSegment(u, s) }
+			if current == "" {
+				current = lastSegment(u)
+			}
+		} else {
+			// Invalid escapes, like the '%' of "creme-10%-ureia", are
+			// left as they are in the raw URL.
+			segment, replace := rawLastSegment(product.URL)
+			withSlug = replace
+			if current == "" {
+				current = segment
+			}
+		}
+	}
+	found := slug.Unsafe(current)
+	if current == "" || found == nil {
+		return product, current, nil
+	}
+
+	record := columns.Record{
+		Sku:           product.Sku,
+		OldSlug:       current,
+		NewSlug:       slug.Generate(current),
+		Departamento:  product.Departamento,
+		Categoria:     product.Categoria,
+		Subcategorias: product.Subcategorias,
+	}
+	if withSlug != nil && record.NewSlug != "" {
+		record.Pairs = []columns.URLPair{{N: 1, From: product.URL, To: withSlug(record.NewSlug)}}
+	}
+	return record, current, found
+}
+
+// rawLastSegment returns the last path segment of rawURL, a URL url.Parse
+// rejects, unescaped when possible, and a function replacing it in rawURL.
+// The segment is empty, and the function nil, when rawURL has no path.
+func rawLastSegment(rawURL string) (string, func(string) string) {
+	rest := ""
+	if i := strings.IndexAny(rawURL, "?#"); i >= 0 {
+		rawURL, rest = rawURL[:i], rawURL[i:]
+	}
+	path := strings.TrimSuffix(rawURL, "/")
+	rest = rawURL[len(path):] + rest
+
+	i := strings.LastIndex(path, "/")
+	prefix, segment := path[:i+1], path[i+1:]
+	if segment == "" || strings.HasSuffix(prefix, "//") {
+		return "", nil
+	}
+	if unescaped, err := url.PathUnescape(segment); err == nil {
+		segment = unescaped
+	}
+	return segment, func(s string) string { return prefix + s + rest }
+}
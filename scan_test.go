@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/columns"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunScan(t *testing.T) {
+	dir := t.TempDir()
+	catalog := filepath.Join(dir, "catalog.csv")
+	output := filepath.Join(dir, "input.csv")
+	require.NoError(t, os.WriteFile(catalog, []byte("Sku,Departamento,Slug,Url\n"+
+		"1,roupas,tamanho:g,https://www.cliquefarma.com.br/roupas/tamanho:g\n"+
+		"2,dermocosmeticos,Protetor Água 50ml,https://www.cliquefarma.com.br/dermocosmeticos/Protetor%20%C3%81gua%2050ml\n"+
+		"3,medicamentos,dipirona-500mg,https://www.cliquefarma.com.br/medicamentos/dipirona-500mg\n"+
+		"4,,,https://www.cliquefarma.com.br/kit+presente\n"+
+		"5,dermo,,https://www.cliquefarma.com.br/dermo/creme-10%-ureia?cor=azul\n"+
+		"6,,,https://www.cliquefarma.com.br/\n",
+	), 0o600))
+
+	err := runScan(context.Background(), []string{"-catalog", catalog, "-output", output})
+	require.EqualError(t, err, "1 products without a slug to clean", "rows written despite the url without slug")
+
+	records := readOutput(t, output)
+	layout, err := columns.Parse(records[0], columns.DefaultAliases())
+	require.NoError(t, err, "written in the input layout")
+	require.Len(t, records, 5)
+
+	var got []columns.Record
+	for _, row := range records[1:] {
+		record := layout.Record(row)
+		got = append(got, columns.Record{Sku: record.Sku, OldSlug: record.OldSlug, NewSlug: record.NewSlug, Departamento: record.Departamento, Pairs: record.Pairs[:1]})
+	}
+	require.Equal(t, []columns.Record{
+		{Sku: "1", OldSlug: "tamanho:g", NewSlug: "tamanho-g", Departamento: "roupas", Pairs: []columns.URLPair{{
+			N: 1, From: "https://www.cliquefarma.com.br/roupas/tamanho:g", To: "https://www.cliquefarma.com.br/roupas/tamanho-g",
+		}}},
+		{Sku: "2", OldSlug: "Protetor Água 50ml", NewSlug: "protetor-agua-50ml", Departamento: "dermocosmeticos", Pairs: []columns.URLPair{{
+			N: 1, From: "https://www.cliquefarma.com.br/dermocosmeticos/Protetor%20%C3%81gua%2050ml", To: "https://www.cliquefarma.com.br/dermocosmeticos/protetor-agua-50ml",
+		}}},
+		{Sku: "4", OldSlug: "kit+presente", NewSlug: "kit-presente", Pairs: []columns.URLPair{{
+			N: 1, From: "https://www.cliquefarma.com.br/kit+presente", To: "https://www.cliquefarma.com.br/kit-presente",
+		}}},
+		{Sku: "5", OldSlug: "creme-10%-ureia", NewSlug: "creme-10-ureia", Departamento: "dermo", Pairs: []columns.URLPair{{
+			N: 1, From: "https://www.cliquefarma.com.br/dermo/creme-10%-ureia?cor=azul", To: "https://www.cliquefarma.com.br/dermo/creme-10-ureia?cor=azul",
+		}}},
+	}, got)
+}
+
+func TestRunScanURLTemplate(t *testing.T) {
+	dir := t.TempDir()
+	catalog := filepath.Join(dir, "catalog.csv")
+	output := filepath.Join(dir, "input.csv")
+	configPath := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(catalog, []byte("Codigo,Departamento,Url Key\n"+
+		"1,Medicamentos,dipirona:500mg\n"+
+		"2,Medicamentos,dipirona-500mg\n"+
+		"3,Medicamentos,dorflex\n",
+	), 0o600))
+
+	err := runScan(context.Background(), []string{"-catalog", catalog, "-output", output})
+	require.EqualError(t, err, "scan: "+catalog+" has no url column, set urlTemplate in the config to build the URLs")
+
+	require.NoError(t, os.WriteFile(configPath, []byte("baseHost: https://www.cliquefarma.com.br\nurlTemplate: \"{base}/{departamento}/{slug}\"\n"), 0o600))
+	err = runScan(context.Background(), []string{"-catalog", catalog, "-output", output, "-config", configPath})
+	require.EqualError(t, err, "1 cleaned slugs shared by other products")
+
+	records := readOutput(t, output)
+	require.Len(t, records, 2, "rows written despite the collision")
+	require.Equal(t, []string{"1", "dipirona:500mg", "dipirona-500mg", "Medicamentos"}, records[1][:4])
+	require.Equal(t, "https://www.cliquefarma.com.br/medicamentos/dipirona-500mg", records[1][11])
+	require.Equal(t, "https://www.cliquefarma.com.br/medicamentos/dipirona:500mg", records[1][8])
+}
+
+func TestRunScanInvalidConfig(t *testing.T) {
+	configPath := writeInvalidConfig(t, t.TempDir())
+	err := runScan(context.Background(), []string{"-catalog", "catalog.csv", "-config", configPath})
+	require.EqualError(t, err, "config: timeout can not be negative, got -1s")
+}
@@ -0,0 +1,155 @@
+package seo
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	headEnd   = regexp.MustCompile(`(?i)</head\s*>`)
+	comment   = regexp.MustCompile(`(?s)<!--.*?-->`)
+	linkTag   = regexp.MustCompile(`(?is)<link\b[^>]*>`)
+	metaTag   = regexp.MustCompile(`(?is)<meta\b[^>]*>`)
+	attribute = regexp.MustCompile(`(?is)([a-z][a-z0-9_:-]*)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+)
+
+// robotsNames are the meta tags and X-Robots-Tag user agents read for the
+// robots directives.
+var robotsNames = map[string]bool{"robots": true, "googlebot": true}
+
+// valueDirectives are the robots directives written as "name: value", told
+// apart from a "googlebot: noindex" user agent prefix.
+var valueDirectives = map[string]bool{
+	"max-snippet":       true,
+	"max-image-preview": true,
+	"max-video-preview": true,
+	"unavailable_after": true,
+}
+
+// Alternate is a hreflang alternate of a page.
+type Alternate struct {
+	Lang string
+	URL  string
+}
+
+// Tags are the indexing hints of a page.
+type Tags struct {
+	// Canonical is the absolute URL of the canonical link, if any.
+	Canonical string
+	// Robots is the content of the robots and googlebot meta tags.
+	Robots []string
+	// XRobotsTag holds the X-Robots-Tag headers of the response.
+	XRobotsTag []string
+	Hreflang   []Alternate
+}
+
+// Parse reads the canonical link, the robots meta tags and the hreflang
+// alternates of the head of an HTML page. Relative URLs are resolved
+// against pageURL.
+func Parse(body string, pageURL string) Tags {
+	if loc := headEnd.FindStringIndex(body); loc != nil {
+		// Links and meta tags in the body are ignored by search engines.
+		body = body[:loc[0]]
+	}
+	body = comment.ReplaceAllString(body, "")
+
+	var tags Tags
+	for _, tag := range linkTag.FindAllString(body, -1) {
+		attrs := Attributes(tag)
+		href := resolve(pageURL, attrs["href"])
+		if href == "" {
+			continue
+		}
+		for _, rel := range strings.Fields(strings.ToLower(attrs["rel"])) {
+			switch {
+			case rel == "canonical" && tags.Canonical == "":
+				tags.Canonical = href
+			case rel == "alternate" && attrs["hreflang"] != "":
+				tags.Hreflang = append(tags.Hreflang, Alternate{Lang: strings.ToLower(attrs["hreflang"]), URL: href})
+			}
+		}
+	}
+	for _, tag := range MetaTags(body) {
+		attrs := Attributes(tag)
+		if robotsNames[strings.ToLower(attrs["name"])] && attrs["content"] != "" {
+			tags.Robots = append(tags.Robots, attrs["content"])
+		}
+	}
+	return tags
+}
+
+// Directives returns the lowercase robots directives of the meta tags and
+// of the X-Robots-Tag headers meant for every crawler or for googlebot.
+func (t Tags) Directives() []string {
+	var directives []string
+	add := func(value string, header bool) {
+		// A header starting with "otherbot:" is for that crawler only, up to
+		// the next user agent.
+		applies := true
+		for _, part := range strings.Split(value, ",") {
+			part = strings.ToLower(strings.TrimSpace(part))
+			if header {
+				if agent, rest, ok := strings.Cut(part, ":"); ok && !valueDirectives[agent] && !strings.Contains(agent, " ") {
+					applies = robotsNames[agent]
+					part = strings.TrimSpace(rest)
+				}
+			}
+			if applies && part != "" {
+				directives = append(directives, part)
+			}
+		}
+	}
+	for _, value := range t.Robots {
+		add(value, false)
+	}
+	for _, value := range t.XRobotsTag {
+		add(value, true)
+	}
+	return directives
+}
+
+// Noindex reports whether the page asks not to be indexed.
+func (t Tags) Noindex() bool {
+	for _, directive := range t.Directives() {
+		if directive == "noindex" || directive == "none" {
+			return true
+		}
+	}
+	return false
+}
+
+// MetaTags returns the meta tags of body.
+func MetaTags(body string) []string {
+	return metaTag.FindAllString(body, -1)
+}
+
+// Attributes returns the attributes of an HTML tag, keyed by their lowercase
+// name, unescaped and trimmed. The first of repeated attributes wins.
+func Attributes(tag string) map[string]string {
+	attrs := map[string]string{}
+	for _, match := range attribute.FindAllStringSubmatch(tag, -1) {
+		name := strings.ToLower(match[1])
+		if _, ok := attrs[name]; ok {
+			continue
+		}
+		attrs[name] = strings.TrimSpace(html.UnescapeString(match[2] + match[3] + match[4]))
+	}
+	return attrs
+}
+
+func resolve(pageURL string, href string) string {
+	if href == "" {
+		return ""
+	}
+	ref, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return ref.String()
+	}
+	return base.ResolveReference(ref).String()
+}
@@ -0,0 +1,88 @@
+package seo_test
+
+import (
+	"testing"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/seo"
+	"github.com/stretchr/testify/require"
+)
+
+const page = "https://www.cliquefarma.com.br/dipirona"
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		desc string
+
+		body     string
+		expected seo.Tags
+	}{
+		{
+			desc: "canonical, robots and hreflang",
+			body: `<html><head>
+				<link rel="canonical" href="https://www.cliquefarma.com.br/dipirona-500mg">
+				<META NAME="robots" CONTENT="noindex, follow">
+				<link rel="alternate" hreflang="pt-BR" href="/dipirona" />
+				<link hreflang='es' rel='alternate' href='https://es.cliquefarma.com/dipirona'>
+				<link rel="stylesheet" href="/style.css">
+			</head><body></body></html>`,
+			expected: seo.Tags{
+				Canonical: "https://www.cliquefarma.com.br/dipirona-500mg",
+				Robots:    []string{"noindex, follow"},
+				Hreflang: []seo.Alternate{
+					{Lang: "pt-br", URL: page},
+					{Lang: "es", URL: "https://es.cliquefarma.com/dipirona"},
+				},
+			},
+		},
+		{
+			desc:     "relative canonical",
+			body:     `<head><link href="dipirona?cor=azul&amp;tamanho=g" rel="canonical"></head>`,
+			expected: seo.Tags{Canonical: "https://www.cliquefarma.com.br/dipirona?cor=azul&tamanho=g"},
+		},
+		{
+			desc:     "first canonical wins",
+			body:     `<head><link rel="canonical" href="/a"><link rel="canonical" href="/b"></head>`,
+			expected: seo.Tags{Canonical: "https://www.cliquefarma.com.br/a"},
+		},
+		{
+			desc: "tags in the body and comments ignored",
+			body: `<head><!-- <meta name="robots" content="noindex"> --></head>
+				<body><link rel="canonical" href="/other"></body>`,
+		},
+		{
+			desc:     "other meta tags ignored",
+			body:     `<head><meta name="description" content="noindex"><meta name="googlebot" content="none"></head>`,
+			expected: seo.Tags{Robots: []string{"none"}},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			require.Equal(t, tC.expected, seo.Parse(tC.body, page))
+		})
+	}
+}
+
+func TestNoindex(t *testing.T) {
+	testCases := []struct {
+		desc string
+
+		tags       seo.Tags
+		directives []string
+		noindex    bool
+	}{
+		{desc: "nothing", tags: seo.Tags{}},
+		{desc: "meta robots", tags: seo.Tags{Robots: []string{"NoIndex, NoFollow"}}, directives: []string{"noindex", "nofollow"}, noindex: true},
+		{desc: "none", tags: seo.Tags{Robots: []string{"none"}}, directives: []string{"none"}, noindex: true},
+		{desc: "header", tags: seo.Tags{XRobotsTag: []string{"noindex"}}, directives: []string{"noindex"}, noindex: true},
+		{desc: "header for googlebot", tags: seo.Tags{XRobotsTag: []string{"googlebot: noindex, nofollow"}}, directives: []string{"noindex", "nofollow"}, noindex: true},
+		{desc: "header for another crawler", tags: seo.Tags{XRobotsTag: []string{"otherbot: noindex, nofollow"}}},
+		{desc: "value directive", tags: seo.Tags{XRobotsTag: []string{"max-snippet: 20"}}, directives: []string{"max-snippet: 20"}},
+		{desc: "index", tags: seo.Tags{Robots: []string{"index, follow"}}, directives: []string{"index", "follow"}},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			require.Equal(t, tC.directives, tC.tags.Directives())
+			require.Equal(t, tC.noindex, tC.tags.Noindex())
+		})
+	}
+}
@@ -0,0 +1,504 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/columns"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/config"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/jobs"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/logger"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/result"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxUploadSize is the largest CSV accepted by POST /jobs.
+	maxUploadSize = 100 << 20
+	// shutdownTimeout is how long requests in progress get to finish on stop.
+	shutdownTimeout = 10 * time.Second
+
+	jobOutputCSV    = "output.csv"
+	jobOutputNDJSON = "output.ndjson"
+)
+
+// runServe serves an HTTP API to upload CSVs and download their analysis.
+// Jobs run one at a time, so the politeness limits of the config hold.
+func runServe(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", ":8080", "address the HTTP server listens on")
+	dir := fs.String("jobs-dir", "jobs", "directory keeping the uploaded CSVs and their results")
+	configPath := fs.String("config", "", "YAML config file with the analysis settings of every job")
+	logFormat := fs.String("log", "", "log format: prod (JSON), dev or nop, defaults to the one of -config")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg := config.Default()
+	if *configPath != "" {
+		if err := config.LoadFile(*configPath, &cfg); err != nil {
+			return err
+		}
+	}
+	if *logFormat != "" {
+		cfg.Log = *logFormat
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	if err := logger.Setup(cfg.Log); err != nil {
+		return err
+	}
+	defer logger.Flush()
+
+	store, err := jobs.Open(*dir)
+	if err != nil {
+		return err
+	}
+	srv := newServer(ctx, cfg, store)
+	srv.start()
+
+	httpServer := &http.Server{Addr: *addr, Handler: srv.routes(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	logger.Info(ctx, "serving analysis jobs", zap.String("addr", *addr), zap.String("jobsDir", *dir))
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	// The job running is left as it is, to be resumed on the next start.
+	srv.wait()
+	return nil
+}
+
+// server runs the uploaded jobs and answers the API.
+type server struct {
+	ctx   context.Context
+	cfg   config.Config
+	store *jobs.Store
+	wake  chan struct{}
+	done  chan struct{}
+
+	mu      sync.Mutex
+	running map[string]*runningJob
+}
+
+// runningJob is the analysis of a job in progress.
+type runningJob struct {
+	reader *RowReader
+	// previous counts the results written before the job was resumed.
+	previous map[string]int
+}
+
+func newServer(ctx context.Context, cfg config.Config, store *jobs.Store) *server {
+	return &server{
+		ctx:     ctx,
+		cfg:     cfg,
+		store:   store,
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		running: map[string]*runningJob{},
+	}
+}
+
+// start runs the pending jobs, then the new ones as they are uploaded,
+// until the server context is done.
+func (s *server) start() {
+	go func() {
+		defer close(s.done)
+		// broken are the jobs whose state could not be saved, skipped so
+		// they are not run over and over.
+		broken := map[string]bool{}
+		for s.ctx.Err() == nil {
+			job, ok := nextJob(s.store.Pending(), broken)
+			if !ok {
+				select {
+				case <-s.ctx.Done():
+				case <-s.wake:
+				}
+				continue
+			}
+			if err := s.runJob(job); err != nil {
+				broken[job.ID] = true
+				logger.Error(s.ctx, err, "failed saving job", zap.String("job", job.ID))
+			}
+		}
+	}()
+}
+
+// wait blocks until the jobs stopped running.
+func (s *server) wait() {
+	<-s.done
+}
+
+func nextJob(pending []jobs.Job, broken map[string]bool) (jobs.Job, bool) {
+	for _, job := range pending {
+		if !broken[job.ID] {
+			return job, true
+		}
+	}
+	return jobs.Job{}, false
+}
+
+// runJob analyzes the input of job, resuming it when it was running. It
+// only fails when the job state could not be saved.
+func (s *server) runJob(job jobs.Job) error {
+	resume := job.State == jobs.Running
+	job, err := s.store.Update(job.ID, func(j *jobs.Job) {
+		j.State = jobs.Running
+		if j.StartedAt == nil {
+			now := time.Now().UTC()
+			j.StartedAt = &now
+		}
+	})
+	if err != nil {
+		return err
+	}
+	logger.Info(s.ctx, "running job", zap.String("job", job.ID), zap.Bool("resume", resume))
+
+	cfg := s.cfg
+	cfg.Input = s.store.Path(job.ID, jobs.InputFile)
+	cfg.Output = s.store.Path(job.ID, jobOutputCSV)
+	cfg.Format = result.CSV
+	cfg.State = ""
+	cfg.Report = ""
+	cfg.Resume = resume
+
+	summary, previous, err := s.analyze(job.ID, cfg)
+	if s.ctx.Err() != nil {
+		// Stopped with the server, the job is resumed on the next start.
+		_, err := s.store.Update(job.ID, func(j *jobs.Job) {
+			setProgress(j, summary, previous)
+		})
+		return err
+	}
+
+	_, updateErr := s.store.Update(job.ID, func(j *jobs.Job) {
+		setProgress(j, summary, previous)
+		now := time.Now().UTC()
+		j.FinishedAt = &now
+		j.State = jobs.Done
+		if err != nil {
+			j.State = jobs.Failed
+			j.Error = err.Error()
+		}
+	})
+	if err != nil {
+		logger.Warn(s.ctx, "job failed", zap.String("job", job.ID), zap.Error(err))
+	}
+	return updateErr
+}
+
+// analyze runs the analysis of a job, writing its results as CSV and NDJSON.
+// It returns the summary of the run and the results written by the runs
+// before it, by status.
+func (s *server) analyze(id string, cfg config.Config) (Summary, map[string]int, error) {
+	ndjsonPath := s.store.Path(id, jobOutputNDJSON)
+	previous := map[string]int{}
+	if cfg.Resume {
+		var err error
+		if previous, err = countStatuses(ndjsonPath); err != nil {
+			return Summary{}, nil, err
+		}
+	}
+
+	file, writer, err := openOutput(ndjsonPath, result.NDJSON, nil, cfg.Resume)
+	if err != nil {
+		return Summary{}, previous, err
+	}
+	defer file.Close()
+
+	summary, err := runWith(s.ctx, cfg, ModeAnalyze, runHooks{
+		writer: writer,
+		started: func(r *RowReader) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.running[id] = &runningJob{reader: r, previous: previous}
+		},
+	})
+	s.mu.Lock()
+	delete(s.running, id)
+	s.mu.Unlock()
+	return summary, previous, err
+}
+
+// setProgress copies the counts of a run to job, adding the results of the
+// runs before it.
+func setProgress(job *jobs.Job, summary Summary, previous map[string]int) {
+	job.Rows = summary.Rows
+	job.Pairs = summary.Pairs + summary.Skipped
+	job.Errors = summary.Errors
+	job.ByStatus = map[string]int{}
+	for status, count := range previous {
+		job.ByStatus[status] += count
+	}
+	for status, count := range summary.ByStatus {
+		job.ByStatus[status] += count
+	}
+}
+
+// countStatuses counts the results of an NDJSON output by status.
+func countStatuses(path string) (map[string]int, error) {
+	counts := map[string]int{}
+	err := readNDJSON(path, func(line []byte) error {
+		var res struct {
+			Status string `json:"status"`
+		}
+		if err := json.Unmarshal(line, &res); err != nil {
+			return fmt.Errorf("failed reading %s: %w", path, err)
+		}
+		counts[res.Status]++
+		return nil
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return counts, nil
+	}
+	return counts, err
+}
+
+// readNDJSON calls fn with every complete line of the file at path. A last
+// line without its newline is being written, or was cut by a crash, and is
+// left out.
+func readNDJSON(path string, fn func(line []byte) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if line = bytes.TrimSpace(line); len(line) == 0 {
+			continue
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/jobs", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, s.listJobs())
+		case http.MethodPost:
+			s.createJob(w, r)
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+	})
+	mux.HandleFunc("/jobs/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		id, file, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/jobs/"), "/")
+		job, err := s.job(id)
+		if err != nil {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		switch file {
+		case "":
+			writeJSON(w, http.StatusOK, job)
+		case "result.csv":
+			s.downloadCSV(w, job)
+		case "result.json":
+			s.downloadJSON(w, job)
+		default:
+			writeError(w, http.StatusNotFound, "unknown job file, expected result.csv or result.json")
+		}
+	})
+	return mux
+}
+
+// createJob saves the uploaded CSV, sent as the request body or as the file
+// field of a multipart form, and queues its analysis.
+func (s *server) createJob(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+
+	var (
+		input    io.Reader = r.Body
+		filename           = r.URL.Query().Get("filename")
+	)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "missing file field: "+err.Error())
+			return
+		}
+		defer file.Close()
+		input, filename = file, header.Filename
+	}
+
+	job, err := s.store.Create(input, filename)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	rows, err := countRows(s.store.Path(job.ID, jobs.InputFile), s.cfg.Columns)
+	if err != nil {
+		s.store.Delete(job.ID)
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	job, err = s.store.Update(job.ID, func(j *jobs.Job) { j.TotalRows = rows })
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// countRows checks the header of the CSV at path and counts its rows.
+func countRows(path string, aliases columns.Aliases) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if err != nil {
+		return 0, fmt.Errorf("failed reading input header: %w", err)
+	}
+	if _, err := columns.Parse(header, aliases); err != nil {
+		return 0, fmt.Errorf("invalid input file: %w", err)
+	}
+	reader.FieldsPerRecord = -1
+
+	rows := 0
+	for {
+		_, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		var parseErr *csv.ParseError
+		if err != nil && !errors.As(err, &parseErr) {
+			return 0, fmt.Errorf("failed reading input: %w", err)
+		}
+		// Invalid rows are counted, the analysis skips them.
+		rows++
+	}
+}
+
+// job returns the job with id, with the progress of its analysis when it is
+// running.
+func (s *server) job(id string) (jobs.Job, error) {
+	job, err := s.store.Get(id)
+	if err != nil {
+		return job, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if running, ok := s.running[id]; ok {
+		setProgress(&job, running.reader.Progress(), running.previous)
+	}
+	return job, nil
+}
+
+func (s *server) listJobs() []jobs.Job {
+	list := s.store.List()
+	for i, job := range list {
+		if job.State == jobs.Running {
+			if current, err := s.job(job.ID); err == nil {
+				list[i] = current
+			}
+		}
+	}
+	return list
+}
+
+// downloadCSV sends the CSV output of job, partial while it is running.
+func (s *server) downloadCSV(w http.ResponseWriter, job jobs.Job) {
+	file, err := os.Open(s.store.Path(job.ID, jobOutputCSV))
+	if errors.Is(err, os.ErrNotExist) {
+		writeError(w, http.StatusNotFound, "job has no results yet, it is "+string(job.State))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", job.ID+".csv"))
+	io.Copy(w, file)
+}
+
+// downloadJSON sends the NDJSON output of job as a JSON array, partial while
+// it is running.
+func (s *server) downloadJSON(w http.ResponseWriter, job jobs.Job) {
+	path := s.store.Path(job.ID, jobOutputNDJSON)
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		writeError(w, http.StatusNotFound, "job has no results yet, it is "+string(job.State))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", job.ID+".json"))
+	out := bufio.NewWriter(w)
+	defer out.Flush()
+
+	separator := "\n"
+	out.WriteString("[")
+	err := readNDJSON(path, func(line []byte) error {
+		out.WriteString(separator)
+		separator = ",\n"
+		_, err := out.Write(line)
+		return err
+	})
+	if err != nil {
+		// The status is sent already, the truncated array tells the client.
+		logger.Warn(s.ctx, "failed sending job results", zap.String("job", job.ID), zap.Error(err))
+		return
+	}
+	out.WriteString("\n]\n")
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
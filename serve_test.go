@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/jobs"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/result"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T, store *jobs.Store) *httptest.Server {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	srv := newServer(ctx, newTestConfig(t.TempDir()), store)
+	srv.start()
+	api := httptest.NewServer(srv.routes())
+	t.Cleanup(func() {
+		api.Close()
+		cancel()
+		srv.wait()
+	})
+	return api
+}
+
+func getJSON(t *testing.T, url string, v any) int {
+	t.Helper()
+	res, err := http.Get(url)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.NoError(t, json.NewDecoder(res.Body).Decode(v))
+	return res.StatusCode
+}
+
+func waitJob(t *testing.T, api *httptest.Server, id string) jobs.Job {
+	t.Helper()
+	var job jobs.Job
+	require.Eventually(t, func() bool {
+		getJSON(t, api.URL+"/jobs/"+id, &job)
+		return job.State == jobs.Done || job.State == jobs.Failed
+	}, 5*time.Second, 10*time.Millisecond)
+	return job
+}
+
+func TestServe(t *testing.T) {
+	site := newTestSite(t)
+	store, err := jobs.Open(t.TempDir())
+	require.NoError(t, err)
+	api := newTestServer(t, store)
+
+	input := inputHeader +
+		fmt.Sprintf("1,old,new,dep,cat,,,,%[1]s/old-1,,,%[1]s/missing-1,,\n", site.URL) +
+		fmt.Sprintf("2,old,new,dep,cat,,,,%[1]s/old-2,,,%[1]s/new-2,,\n", site.URL)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "products.csv")
+	require.NoError(t, err)
+	_, err = part.Write([]byte(input))
+	require.NoError(t, err)
+	require.NoError(t, form.Close())
+
+	res, err := http.Post(api.URL+"/jobs", form.FormDataContentType(), &body)
+	require.NoError(t, err)
+	var created jobs.Job
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&created))
+	res.Body.Close()
+	require.Equal(t, http.StatusAccepted, res.StatusCode)
+	require.Equal(t, "/jobs/"+created.ID, res.Header.Get("Location"))
+	require.Equal(t, "products.csv", created.Filename)
+	require.Equal(t, 2, created.TotalRows)
+
+	job := waitJob(t, api, created.ID)
+	require.Equal(t, jobs.Done, job.State, job.Error)
+	require.Equal(t, 2, job.Rows)
+	require.Equal(t, 2, job.Pairs)
+	require.Equal(t, map[string]int{"REDIRECIONAR": 1, "ANALISAR": 1}, job.ByStatus)
+	require.NotNil(t, job.FinishedAt)
+
+	var list []jobs.Job
+	require.Equal(t, http.StatusOK, getJSON(t, api.URL+"/jobs", &list))
+	require.Len(t, list, 1)
+
+	res, err = http.Get(api.URL + "/jobs/" + created.ID + "/result.csv")
+	require.NoError(t, err)
+	csvData, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, "text/csv; charset=utf-8", res.Header.Get("Content-Type"))
+	require.Len(t, strings.Split(strings.TrimSuffix(string(csvData), "\n"), "\n"), 3)
+
+	var results []result.Result
+	require.Equal(t, http.StatusOK, getJSON(t, api.URL+"/jobs/"+created.ID+"/result.json", &results))
+	require.Len(t, results, 2)
+	require.ElementsMatch(t, []string{"1", "2"}, []string{results[0].Sku, results[1].Sku})
+}
+
+func TestServeRejectsInvalidInput(t *testing.T) {
+	store, err := jobs.Open(t.TempDir())
+	require.NoError(t, err)
+	api := newTestServer(t, store)
+
+	res, err := http.Post(api.URL+"/jobs?filename=bad.csv", "text/csv", strings.NewReader("Name,Price\nfoo,1\n"))
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+	require.Empty(t, store.List())
+
+	var failure map[string]string
+	require.Equal(t, http.StatusNotFound, getJSON(t, api.URL+"/jobs/unknown", &failure))
+	require.Equal(t, jobs.ErrNotFound.Error(), failure["error"])
+}
+
+func TestServeResumesRunningJobs(t *testing.T) {
+	site := newTestSite(t)
+	dir := t.TempDir()
+	store, err := jobs.Open(dir)
+	require.NoError(t, err)
+
+	input := inputHeader +
+		fmt.Sprintf("1,old,new,,,,,,%[1]s/old-1,,,%[1]s/missing-1,,\n", site.URL) +
+		fmt.Sprintf("2,old,new,,,,,,%[1]s/old-2,,,%[1]s/missing-2,,\n", site.URL)
+	job, err := store.Create(strings.NewReader(input), "products.csv")
+	require.NoError(t, err)
+
+	// A server stopped after analyzing the first pair.
+	cfg := newTestConfig(t.TempDir())
+	cfg.Input = store.Path(job.ID, jobs.InputFile)
+	cfg.Output = store.Path(job.ID, jobOutputNDJSON)
+	require.NoError(t, os.WriteFile(cfg.Input, []byte(input[:strings.Index(input, "\n2,")+1]), 0o600))
+	require.NoError(t, run(context.Background(), cfg, ModeAnalyze))
+	require.NoError(t, os.Rename(cfg.Output+".state", store.Path(job.ID, jobOutputCSV+".state")))
+	require.NoError(t, os.WriteFile(cfg.Input, []byte(input), 0o600))
+	_, err = store.Update(job.ID, func(j *jobs.Job) { j.State = jobs.Running })
+	require.NoError(t, err)
+
+	store, err = jobs.Open(dir)
+	require.NoError(t, err)
+	api := newTestServer(t, store)
+
+	job = waitJob(t, api, job.ID)
+	require.Equal(t, jobs.Done, job.State, job.Error)
+	require.Equal(t, 2, job.Pairs)
+	require.Equal(t, map[string]int{"REDIRECIONAR": 2}, job.ByStatus)
+
+	var results []result.Result
+	getJSON(t, api.URL+"/jobs/"+job.ID+"/result.json", &results)
+	require.Len(t, results, 2)
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/columns"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/config"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/graph"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/metadata"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/sitemap"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/slug"
+
+	inputhttp "github.com/castmetal/cliquefarma-analize-redirect-csv/http"
+)
+
+// Kinds of the issues found comparing the sitemap to the input.
+const (
+	paraNotInSitemap = "PARA_NOT_IN_SITEMAP"
+	deInSitemap      = "DE_IN_SITEMAP"
+	slugNotCovered   = "SLUG_NOT_COVERED"
+)
+
+// sitemapIssue is a URL of the sitemap or of the input the other one
+// disagrees with.
+type sitemapIssue struct {
+	Kind     string
+	Sku      string
+	URL      string
+	Expected string
+	Detail   string
+}
+
+// runSitemap reads a sitemap, or a sitemap index, and checks it against the
+// De/Para pairs of the input: Para URLs should be listed, De URLs should not,
+// and listed URLs with special characters in their slug should have a row.
+func runSitemap(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("sitemap", flag.ContinueOnError)
+	location := fs.String("sitemap", "", "URL or file of the sitemap or sitemap index, gzipped or not")
+	input := fs.String("input", config.DefaultInput, "CSV file with the products to check, empty to only look for slugs with special characters")
+	output := fs.String("output", "", "CSV file to write the issues to, standard output when empty")
+	rows := fs.String("rows", "", "CSV file to write the listed URLs with special characters not covered by the input to, in the input layout, ready to analyze")
+	configPath := fs.String("config", "", "YAML config file with the input column aliases, timeout and user agent")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *location == "" {
+		return fmt.Errorf("sitemap: -sitemap is required")
+	}
+
+	cfg := config.Default()
+	if *configPath != "" {
+		if err := config.LoadFile(*configPath, &cfg); err != nil {
+			return err
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	urls, err := sitemap.Read(ctx, *location, sitemapOpener(cfg))
+	if err != nil {
+		return err
+	}
+
+	var froms, tos map[string][]string
+	if *input != "" {
+		if froms, tos, err = readPairURLs(*input, cfg); err != nil {
+			return err
+		}
+	}
+	issues, uncovered := checkSitemap(urls, froms, tos)
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("failed creating file: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+	if err := writeSitemapIssues(w, issues); err != nil {
+		return err
+	}
+
+	if *rows != "" {
+		if err := writeInputRows(*rows, uncovered); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "wrote %d rows to %s\n", len(uncovered), *rows)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(issues) > 0 {
+		kinds := make([]string, len(issues))
+		for i, issue := range issues {
+			kinds[i] = issue.Kind
+		}
+		return fmt.Errorf("%s in %d sitemap urls", countKinds(kinds), len(urls))
+	}
+	return nil
+}
+
+// sitemapOpener opens local files, and fetches URLs with the timeout and
+// user agent of cfg.
+func sitemapOpener(cfg config.Config) sitemap.Opener {
+	return func(ctx context.Context, location string) (io.ReadCloser, error) {
+		if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+			return os.Open(location)
+		}
+		client, err := inputhttp.New(ctx, metadata.Map{
+			"targetURL": location,
+			"method":    http.MethodGet,
+			"timeout":   cfg.Timeout,
+			"headers": map[string]interface{}{
+				"User-Agent": cfg.UserAgent,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+		if err != nil {
+			return nil, err
+		}
+		res, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if res.StatusCode != http.StatusOK {
+			drain(res)
+			return nil, fmt.Errorf("answered %d", res.StatusCode)
+		}
+		return res.Body, nil
+	}
+}
+
+// readPairURLs returns the skus of every De and Para URL of the input, keyed
+// by graph.Key. Missing URLs are built with the URL template, when set.
+func readPairURLs(path string, cfg config.Config) (map[string][]string, map[string][]string, error) {
+	layout, rows, err := readInput(path, cfg.Columns)
+	if err != nil {
+		return nil, nil, err
+	}
+	edges, err := recordEdges(cfg, layout, rows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	froms, tos := map[string][]string{}, map[string][]string{}
+	for _, edge := range edges {
+		from, to := graph.Key(edge.From), graph.Key(edge.To)
+		froms[from] = appendSku(froms[from], edge.Sku)
+		tos[to] = appendSku(tos[to], edge.Sku)
+	}
+	return froms, tos, nil
+}
+
+// checkSitemap compares the sitemap urls to the De and Para URLs of the
+// input, when given. It also returns the rows to add to the input for the
+// listed URLs whose slug has special characters.
+func checkSitemap(urls []sitemap.URL, froms map[string][]string, tos map[string][]string) ([]sitemapIssue, []columns.Record) {
+	var issues []sitemapIssue
+	var uncovered []columns.Record
+	listed := map[string]bool{}
+
+	for _, u := range urls {
+		key := graph.Key(u.Loc)
+		if listed[key] {
+			continue
+		}
+		listed[key] = true
+
+		if skus, ok := froms[key]; ok {
+			issues = append(issues, sitemapIssue{Kind: deInSitemap, Sku: strings.Join(skus, ","), URL: u.Loc, Detail: "listed in " + u.Sitemap})
+			continue
+		}
+		record, ok := cleanSlugRecord(u.Loc)
+		if !ok {
+			continue
+		}
+		issues = append(issues, sitemapIssue{Kind: slugNotCovered, URL: u.Loc, Expected: record.Pairs[0].To, Detail: "listed in " + u.Sitemap})
+		uncovered = append(uncovered, record)
+	}
+
+	var missing []string
+	for key := range tos {
+		if !listed[key] {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	for _, key := range missing {
+		issues = append(issues, sitemapIssue{Kind: paraNotInSitemap, Sku: strings.Join(tos[key], ","), URL: key})
+	}
+	return issues, uncovered
+}
+
+// cleanSlugRecord returns the input row redirecting rawURL to the same URL
+// with its last path segment turned into a slug, false when that segment
+// already is one.
+func cleanSlugRecord(rawURL string) (columns.Record, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return columns.Record{}, false
+	}
+	old := lastSegment(u)
+	if old == "" || slug.Valid(old) {
+		return columns.Record{}, false
+	}
+	clean := slug.Generate(old)
+	if clean == "" {
+		return columns.Record{}, false
+	}
+
+	record := columns.Record{OldSlug: old, NewSlug: clean}
+	if segments := strings.Split(strings.Trim(u.Path, "/"), "/"); len(segments) > 1 {
+		record.Departamento = segments[0]
+	}
+	record.Pairs = []columns.URLPair{{N: 1, From: rawURL, To: withLastSegment(u, clean)}}
+	return record, true
+}
+
+// lastSegment returns the last segment of the path of u, unescaped.
+func lastSegment(u *url.URL) string {
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	return segments[len(segments)-1]
+}
+
+// withLastSegment returns u with the last segment of its path replaced by s.
+func withLastSegment(u *url.URL, s string) string {
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	segments[len(segments)-1] = s
+	to := *u
+	to.Path = "/" + strings.Join(segments, "/")
+	if strings.HasSuffix(u.Path, "/") {
+		to.Path += "/"
+	}
+	to.RawPath = ""
+	return to.String()
+}
+
+func writeSitemapIssues(w io.Writer, issues []sitemapIssue) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"Kind", "Sku", "URL", "Expected", "Detail"})
+	for _, issue := range issues {
+		writer.Write([]string{issue.Kind, issue.Sku, issue.URL, issue.Expected, issue.Detail})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed writing issues: %w", err)
+	}
+	return nil
+}
+
+func appendSku(skus []string, sku string) []string {
+	for _, s := range skus {
+		if s == sku {
+			return skus
+		}
+	}
+	return append(skus, sku)
+}
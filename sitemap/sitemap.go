@@ -0,0 +1,132 @@
+package sitemap
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// MaxDepth is how deep sitemap indexes are followed, an index listing
+// indexes being depth 2.
+const MaxDepth = 3
+
+// gzipMagic starts every gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// Opener returns the content of a sitemap at location, a URL or a file.
+type Opener func(ctx context.Context, location string) (io.ReadCloser, error)
+
+// URL is a page listed in a sitemap.
+type URL struct {
+	Loc     string
+	LastMod string
+	// Sitemap is the location of the sitemap listing the page.
+	Sitemap string
+}
+
+// document is either a urlset or a sitemapindex.
+type document struct {
+	XMLName  xml.Name
+	URLs     []entry `xml:"url"`
+	Sitemaps []entry `xml:"sitemap"`
+}
+
+type entry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// Parse reads a sitemap, gzipped or not. It returns the pages of a urlset
+// or the sitemaps of a sitemapindex.
+func Parse(r io.Reader) ([]URL, []string, error) {
+	buffered := bufio.NewReader(r)
+	if magic, err := buffered.Peek(len(gzipMagic)); err == nil && string(magic) == string(gzipMagic) {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, nil, fmt.Errorf("sitemap: could not read gzip: %w", err)
+		}
+		defer gz.Close()
+		return parse(gz)
+	}
+	return parse(buffered)
+}
+
+func parse(r io.Reader) ([]URL, []string, error) {
+	var doc document
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, nil, fmt.Errorf("sitemap: could not parse xml: %w", err)
+	}
+	switch doc.XMLName.Local {
+	case "urlset":
+		urls := make([]URL, 0, len(doc.URLs))
+		for _, e := range doc.URLs {
+			if loc := strings.TrimSpace(e.Loc); loc != "" {
+				urls = append(urls, URL{Loc: loc, LastMod: strings.TrimSpace(e.LastMod)})
+			}
+		}
+		return urls, nil, nil
+	case "sitemapindex":
+		var sitemaps []string
+		for _, e := range doc.Sitemaps {
+			if loc := strings.TrimSpace(e.Loc); loc != "" {
+				sitemaps = append(sitemaps, loc)
+			}
+		}
+		return nil, sitemaps, nil
+	}
+	return nil, nil, fmt.Errorf("sitemap: unknown root element <%s>, expected <urlset> or <sitemapindex>", doc.XMLName.Local)
+}
+
+// Read returns every page listed by the sitemap at location, following the
+// sitemaps of an index up to MaxDepth. Each sitemap is read once.
+func Read(ctx context.Context, location string, open Opener) ([]URL, error) {
+	var urls []URL
+	visited := map[string]bool{}
+	level := []string{location}
+
+	for depth := 1; len(level) > 0; depth++ {
+		var next []string
+		for _, loc := range level {
+			if visited[loc] {
+				continue
+			}
+			visited[loc] = true
+			if err := ctx.Err(); err != nil {
+				return urls, err
+			}
+
+			pages, sitemaps, err := readOne(ctx, loc, open)
+			if err != nil {
+				return urls, err
+			}
+			for _, page := range pages {
+				page.Sitemap = loc
+				urls = append(urls, page)
+			}
+			if len(sitemaps) > 0 && depth >= MaxDepth {
+				return urls, fmt.Errorf("sitemap: %s nests indexes deeper than %d levels", loc, MaxDepth)
+			}
+			next = append(next, sitemaps...)
+		}
+		level = next
+	}
+	return urls, nil
+}
+
+func readOne(ctx context.Context, location string, open Opener) ([]URL, []string, error) {
+	body, err := open(ctx, location)
+	if err != nil {
+		return nil, nil, fmt.Errorf("sitemap: could not open %s: %w", location, err)
+	}
+	defer body.Close()
+
+	urls, sitemaps, err := Parse(body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w, in %s", err, location)
+	}
+	return urls, sitemaps, nil
+}
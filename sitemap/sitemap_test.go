@@ -0,0 +1,114 @@
+package sitemap_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/sitemap"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	urlset = `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>https://www.cliquefarma.com.br/a</loc><lastmod>2024-01-01</lastmod></url>
+  <url><loc> https://www.cliquefarma.com.br/b </loc></url>
+</urlset>`
+	index = `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>products.xml.gz</loc></sitemap>
+  <sitemap><loc>categories.xml</loc></sitemap>
+  <sitemap><loc>products.xml.gz</loc></sitemap>
+</sitemapindex>`
+)
+
+func gzipped(t *testing.T, s string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write([]byte(s))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	return buf.String()
+}
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		desc string
+
+		body             string
+		urls             []sitemap.URL
+		sitemaps         []string
+		errAssertionFunc require.ErrorAssertionFunc
+	}{
+		{
+			desc:             "urlset",
+			body:             urlset,
+			urls:             []sitemap.URL{{Loc: "https://www.cliquefarma.com.br/a", LastMod: "2024-01-01"}, {Loc: "https://www.cliquefarma.com.br/b"}},
+			errAssertionFunc: require.NoError,
+		},
+		{
+			desc:             "gzipped urlset",
+			body:             gzipped(t, urlset),
+			urls:             []sitemap.URL{{Loc: "https://www.cliquefarma.com.br/a", LastMod: "2024-01-01"}, {Loc: "https://www.cliquefarma.com.br/b"}},
+			errAssertionFunc: require.NoError,
+		},
+		{
+			desc:             "index",
+			body:             index,
+			sitemaps:         []string{"products.xml.gz", "categories.xml", "products.xml.gz"},
+			errAssertionFunc: require.NoError,
+		},
+		{desc: "html", body: "<html></html>", errAssertionFunc: require.Error},
+		{desc: "not xml", body: "Sku,Old Slug", errAssertionFunc: require.Error},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			urls, sitemaps, err := sitemap.Parse(strings.NewReader(tC.body))
+			tC.errAssertionFunc(t, err)
+			require.Equal(t, tC.urls, urls)
+			require.Equal(t, tC.sitemaps, sitemaps)
+		})
+	}
+}
+
+func TestRead(t *testing.T) {
+	files := map[string]string{
+		"sitemap.xml":     index,
+		"products.xml.gz": gzipped(t, urlset),
+		"categories.xml":  `<urlset><url><loc>https://www.cliquefarma.com.br/c</loc></url></urlset>`,
+		"deep.xml":        `<sitemapindex><sitemap><loc>deeper.xml</loc></sitemap></sitemapindex>`,
+		"deeper.xml":      `<sitemapindex><sitemap><loc>deepest.xml</loc></sitemap></sitemapindex>`,
+		"deepest.xml":     `<sitemapindex><sitemap><loc>categories.xml</loc></sitemap></sitemapindex>`,
+	}
+	var opened []string
+	open := func(ctx context.Context, location string) (io.ReadCloser, error) {
+		opened = append(opened, location)
+		body, ok := files[location]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return io.NopCloser(strings.NewReader(body)), nil
+	}
+
+	urls, err := sitemap.Read(context.Background(), "sitemap.xml", open)
+	require.NoError(t, err)
+	require.Equal(t, []sitemap.URL{
+		{Loc: "https://www.cliquefarma.com.br/a", LastMod: "2024-01-01", Sitemap: "products.xml.gz"},
+		{Loc: "https://www.cliquefarma.com.br/b", Sitemap: "products.xml.gz"},
+		{Loc: "https://www.cliquefarma.com.br/c", Sitemap: "categories.xml"},
+	}, urls)
+	require.Equal(t, []string{"sitemap.xml", "products.xml.gz", "categories.xml"}, opened, "each sitemap read once")
+
+	_, err = sitemap.Read(context.Background(), "deep.xml", open)
+	require.Error(t, err, "indexes nested too deep")
+
+	_, err = sitemap.Read(context.Background(), "missing.xml", open)
+	require.True(t, errors.Is(err, os.ErrNotExist))
+}
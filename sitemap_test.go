@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/columns"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/config"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/graph"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/sitemap"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunSitemap(t *testing.T) {
+	var products bytes.Buffer
+	gz := gzip.NewWriter(&products)
+	fmt.Fprint(gz, `<urlset>
+  <url><loc>https://www.cliquefarma.com.br/medicamentos/old-a</loc></url>
+  <url><loc>https://www.cliquefarma.com.br/medicamentos/new-a</loc></url>
+  <url><loc>https://www.cliquefarma.com.br/medicamentos/Dipirona%20500mg:%20caixa</loc></url>
+  <url><loc>https://www.cliquefarma.com.br/medicamentos/dorflex</loc></url>
+</urlset>`)
+	require.NoError(t, gz.Close())
+
+	mux := http.NewServeMux()
+	site := httptest.NewServer(mux)
+	t.Cleanup(site.Close)
+	mux.HandleFunc("/sitemap.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<sitemapindex><sitemap><loc>%s/products.xml.gz</loc></sitemap></sitemapindex>`, site.URL)
+	})
+	mux.HandleFunc("/products.xml.gz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(products.Bytes())
+	})
+
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.csv")
+	output := filepath.Join(dir, "issues.csv")
+	rows := filepath.Join(dir, "rows.csv")
+	require.NoError(t, os.WriteFile(input, []byte(inputHeader+
+		"1,old-a,new-a,,,,,,https://www.cliquefarma.com.br/medicamentos/old-a,,,https://www.cliquefarma.com.br/medicamentos/new-a,,\n"+
+		"2,old-b,new-b,,,,,,https://www.cliquefarma.com.br/medicamentos/old-b,,,https://WWW.cliquefarma.com.br/medicamentos/new-b,,\n",
+	), 0o600))
+
+	err := runSitemap(context.Background(), []string{"-sitemap", site.URL + "/sitemap.xml", "-input", input, "-output", output, "-rows", rows})
+	require.EqualError(t, err, "1 DE_IN_SITEMAP, 1 PARA_NOT_IN_SITEMAP, 1 SLUG_NOT_COVERED in 4 sitemap urls")
+
+	records := readOutput(t, output)
+	require.Equal(t, [][]string{
+		{"Kind", "Sku", "URL", "Expected", "Detail"},
+		{"DE_IN_SITEMAP", "1", "https://www.cliquefarma.com.br/medicamentos/old-a", "", "listed in " + site.URL + "/products.xml.gz"},
+		{"SLUG_NOT_COVERED", "", "https://www.cliquefarma.com.br/medicamentos/Dipirona%20500mg:%20caixa", "https://www.cliquefarma.com.br/medicamentos/dipirona-500mg-caixa", "listed in " + site.URL + "/products.xml.gz"},
+		{"PARA_NOT_IN_SITEMAP", "2", "https://www.cliquefarma.com.br/medicamentos/new-b", "", ""},
+	}, records)
+
+	// The rows are read back as an input.
+	written := readOutput(t, rows)
+	require.Len(t, written, 2)
+	layout, err := columns.Parse(written[0], config.Default().Columns)
+	require.NoError(t, err)
+	record := layout.Record(written[1])
+	require.Equal(t, "Dipirona 500mg: caixa", record.OldSlug)
+	require.Equal(t, "dipirona-500mg-caixa", record.NewSlug)
+	require.Equal(t, "medicamentos", record.Departamento)
+	require.Equal(t, "https://www.cliquefarma.com.br/medicamentos/dipirona-500mg-caixa", record.Pairs[0].To)
+}
+
+func TestRunSitemapInvalidConfig(t *testing.T) {
+	configPath := writeInvalidConfig(t, t.TempDir())
+	err := runSitemap(context.Background(), []string{"-sitemap", "sitemap.xml", "-config", configPath})
+	require.EqualError(t, err, "config: timeout can not be negative, got -1s")
+}
+
+func TestCheckSitemapEncodedURLs(t *testing.T) {
+	froms := map[string][]string{graph.Key("https://www.cliquefarma.com.br/roupas/camiseta-tamanho:g"): {"1"}}
+	tos := map[string][]string{graph.Key("https://www.cliquefarma.com.br/roupas/camiseta-tamanho-g"): {"1"}}
+	urls := []sitemap.URL{
+		{Loc: "https://www.cliquefarma.com.br/roupas/camiseta-tamanho%3Ag", Sitemap: "sitemap.xml"},
+		{Loc: "https://www.cliquefarma.com.br/roupas/camiseta-tamanho-g", Sitemap: "sitemap.xml"},
+	}
+
+	issues, uncovered := checkSitemap(urls, froms, tos)
+	require.Equal(t, []sitemapIssue{{
+		Kind: deInSitemap, Sku: "1", URL: "https://www.cliquefarma.com.br/roupas/camiseta-tamanho%3Ag", Detail: "listed in sitemap.xml",
+	}}, issues)
+	require.Empty(t, uncovered, "no row added for a De URL of the input")
+}
@@ -0,0 +1,205 @@
+package slug
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Kind is a problem found in the slugs of the input.
+type Kind string
+
+const (
+	// Mismatch is a New Slug other than the one generated from the Old Slug.
+	Mismatch Kind = "MISMATCH"
+	// Missing is an Old Slug to change without New Slug.
+	Missing Kind = "MISSING"
+	// Collision is a new slug given to more than one Sku.
+	Collision Kind = "COLLISION"
+)
+
+// accents turns the letters of Portuguese, lowercase, into plain ASCII.
+var accents = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ã", "a", "ä", "a", "ª", "a",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i",
+	"ó", "o", "ò", "o", "ô", "o", "õ", "o", "ö", "o", "º", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u",
+	"ç", "c", "ñ", "n",
+	"&", "-e-",
+)
+
+// Generate returns the slug expected for old: lowercase, without accents,
+// every character other than a letter or digit turned into a hyphen, like
+// ':' in "tamanho:g", and hyphens collapsed and trimmed.
+func Generate(old string) string {
+	s := accents.Replace(strings.ToLower(strings.TrimSpace(old)))
+
+	var b strings.Builder
+	hyphen := false
+	for _, r := range s {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			hyphen = false
+			b.WriteRune(r)
+			continue
+		}
+		hyphen = true
+	}
+	return b.String()
+}
+
+// Valid reports whether s is already a slug Generate would leave as it is.
+func Valid(s string) bool {
+	return s != "" && Generate(s) == s
+}
+
+// reasons are the names Unsafe gives, in the order it lists them.
+var reasons = []string{"colon", "accent", "space", "percent", "uppercase", "plus", "ampersand", "emoji", "hyphen", "other"}
+
+// Unsafe returns why s is not a valid slug, among "colon", "accent",
+// "space", "percent", "uppercase", "plus", "ampersand", "emoji", "hyphen"
+// for leading, trailing or repeated hyphens, and "other" characters. It
+// returns nil for a valid slug.
+func Unsafe(s string) []string {
+	if Valid(s) {
+		return nil
+	}
+	found := map[string]bool{}
+	for _, r := range s {
+		switch {
+		case r == ':':
+			found["colon"] = true
+		case r == '%':
+			found["percent"] = true
+		case r == '+':
+			found["plus"] = true
+		case r == '&':
+			found["ampersand"] = true
+		case unicode.IsSpace(r):
+			found["space"] = true
+		case r >= 'A' && r <= 'Z':
+			found["uppercase"] = true
+		case r > unicode.MaxASCII && unicode.IsLetter(r):
+			found["accent"] = true
+			if unicode.IsUpper(r) {
+				found["uppercase"] = true
+			}
+		case isEmoji(r):
+			found["emoji"] = true
+		case (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-':
+		default:
+			found["other"] = true
+		}
+	}
+	if strings.HasPrefix(s, "-") || strings.HasSuffix(s, "-") || strings.Contains(s, "--") {
+		found["hyphen"] = true
+	}
+	if len(found) == 0 {
+		found["other"] = true
+	}
+
+	var list []string
+	for _, reason := range reasons {
+		if found[reason] {
+			list = append(list, reason)
+		}
+	}
+	return list
+}
+
+// isEmoji reports whether r is a pictograph, or one of the joiners and
+// selectors emoji are made of.
+func isEmoji(r rune) bool {
+	return unicode.Is(unicode.So, r) || (r >= 0x1f000 && r <= 0x1faff) || r == 0x200d || (r >= 0xfe00 && r <= 0xfe0f)
+}
+
+// Row is the Sku and slugs of an input row.
+type Row struct {
+	Sku     string
+	OldSlug string
+	NewSlug string
+}
+
+// Issue is a problem found in the slugs of a row, or of rows sharing a slug.
+type Issue struct {
+	Kind Kind
+	// Sku of the row, or the Skus colliding, comma separated.
+	Sku      string
+	OldSlug  string
+	NewSlug  string
+	Expected string
+	Detail   string
+}
+
+// Check compares the New Slug of every row to the one generated from its Old
+// Slug, then looks for Skus ending with the same slug. Rows without New Slug
+// keep the generated one. Identical rows, like a Sku repeated for each of
+// its URLs, are checked once, while each slug pair of a Sku is checked.
+func Check(rows []Row) []Issue {
+	var issues []Issue
+	bySlug := map[string][]string{}
+	seen := map[Row]bool{}
+
+	for _, row := range rows {
+		if row.OldSlug == "" && row.NewSlug == "" {
+			continue
+		}
+		if seen[row] {
+			continue
+		}
+		seen[row] = true
+
+		expected := Generate(row.OldSlug)
+		final := row.NewSlug
+		switch {
+		case row.NewSlug == "" && expected != row.OldSlug:
+			issues = append(issues, Issue{Kind: Missing, Sku: row.Sku, OldSlug: row.OldSlug, Expected: expected,
+				Detail: "Old Slug is not a valid slug and has no New Slug"})
+			final = expected
+		case row.NewSlug == "":
+			final = expected
+		case row.OldSlug != "" && row.NewSlug != expected:
+			issues = append(issues, Issue{Kind: Mismatch, Sku: row.Sku, OldSlug: row.OldSlug, NewSlug: row.NewSlug, Expected: expected,
+				Detail: mismatchDetail(row.NewSlug)})
+		}
+		bySlug[final] = appendUnique(bySlug[final], row.Sku)
+	}
+
+	slugs := make([]string, 0, len(bySlug))
+	for s, skus := range bySlug {
+		if len(skus) > 1 {
+			slugs = append(slugs, s)
+		}
+	}
+	sort.Strings(slugs)
+	for _, s := range slugs {
+		issues = append(issues, Issue{
+			Kind:     Collision,
+			Sku:      strings.Join(bySlug[s], ","),
+			NewSlug:  s,
+			Expected: s,
+			Detail:   fmt.Sprintf("new slug shared by %d skus", len(bySlug[s])),
+		})
+	}
+	return issues
+}
+
+func mismatchDetail(newSlug string) string {
+	if !Valid(newSlug) {
+		return "New Slug is not a valid slug"
+	}
+	return "New Slug differs from the one generated from Old Slug"
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, v := range list {
+		if v == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -0,0 +1,76 @@
+package slug_test
+
+import (
+	"testing"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/slug"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	testCases := []struct {
+		desc string
+
+		old      string
+		expected string
+	}{
+		{desc: "colon", old: "scrub-masculino-brim-leve-azul-tamanho:g", expected: "scrub-masculino-brim-leve-azul-tamanho-g"},
+		{desc: "accents", old: "Protetor Solar Anthelios Água 50ml", expected: "protetor-solar-anthelios-agua-50ml"},
+		{desc: "cedilla and tilde", old: "loção-hidratante-maçã-pão", expected: "locao-hidratante-maca-pao"},
+		{desc: "ampersand", old: "Johnson & Johnson", expected: "johnson-e-johnson"},
+		{desc: "collapsed hyphens", old: "--dipirona  500mg / 10 comp.--", expected: "dipirona-500mg-10-comp"},
+		{desc: "already a slug", old: "dipirona-500mg", expected: "dipirona-500mg"},
+		{desc: "ordinal", old: "vitamina-nº1", expected: "vitamina-no1"},
+		{desc: "empty", old: "", expected: ""},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			require.Equal(t, tC.expected, slug.Generate(tC.old))
+		})
+	}
+}
+
+func TestUnsafe(t *testing.T) {
+	testCases := []struct {
+		desc string
+
+		slug    string
+		reasons []string
+	}{
+		{desc: "valid", slug: "dipirona-500mg"},
+		{desc: "colon", slug: "tamanho:g", reasons: []string{"colon"}},
+		{desc: "accents and uppercase", slug: "Protetor-Água", reasons: []string{"accent", "uppercase"}},
+		{desc: "uppercase accent", slug: "protetor-ÁGUA", reasons: []string{"accent", "uppercase"}},
+		{desc: "space, percent and plus", slug: "creme 10%+ureia", reasons: []string{"space", "percent", "plus"}},
+		{desc: "ampersand", slug: "johnson&johnson", reasons: []string{"ampersand"}},
+		{desc: "emoji", slug: "kit-presente-🎁", reasons: []string{"emoji"}},
+		{desc: "hyphens", slug: "dipirona--500mg", reasons: []string{"hyphen"}},
+		{desc: "other", slug: "dipirona/500mg", reasons: []string{"other"}},
+		{desc: "empty", slug: "", reasons: []string{"other"}},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			require.Equal(t, tC.reasons, slug.Unsafe(tC.slug))
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	issues := slug.Check([]slug.Row{
+		{Sku: "1", OldSlug: "tamanho:g", NewSlug: "tamanho-g"},
+		{Sku: "1", OldSlug: "tamanho:g", NewSlug: "tamanho-g"},
+		{Sku: "2", OldSlug: "tamanho:m", NewSlug: "tamanho_m"},
+		{Sku: "3", OldSlug: "cor:azul", NewSlug: "azul"},
+		{Sku: "4", OldSlug: "tamanho:G"},
+		{Sku: "5", OldSlug: "dipirona-500mg"},
+		{Sku: "6", OldSlug: "dipirona:500mg", NewSlug: "dipirona-500mg"},
+	})
+
+	require.Equal(t, []slug.Issue{
+		{Kind: slug.Mismatch, Sku: "2", OldSlug: "tamanho:m", NewSlug: "tamanho_m", Expected: "tamanho-m", Detail: "New Slug is not a valid slug"},
+		{Kind: slug.Mismatch, Sku: "3", OldSlug: "cor:azul", NewSlug: "azul", Expected: "cor-azul", Detail: "New Slug differs from the one generated from Old Slug"},
+		{Kind: slug.Missing, Sku: "4", OldSlug: "tamanho:G", Expected: "tamanho-g", Detail: "Old Slug is not a valid slug and has no New Slug"},
+		{Kind: slug.Collision, Sku: "5,6", NewSlug: "dipirona-500mg", Expected: "dipirona-500mg", Detail: "new slug shared by 2 skus"},
+		{Kind: slug.Collision, Sku: "1,4", NewSlug: "tamanho-g", Expected: "tamanho-g", Detail: "new slug shared by 2 skus"},
+	}, issues)
+}
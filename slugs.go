@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/config"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/slug"
+)
+
+// runSlugs checks the New Slug of every row against the one generated from
+// its Old Slug, and looks for Skus ending with the same slug.
+func runSlugs(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("slugs", flag.ContinueOnError)
+	input := fs.String("input", config.DefaultInput, "CSV file with the products to check")
+	output := fs.String("output", "", "CSV file to write the issues to, standard output when empty")
+	configPath := fs.String("config", "", "YAML config file with the input column aliases")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg := config.Default()
+	if *configPath != "" {
+		if err := config.LoadFile(*configPath, &cfg); err != nil {
+			return err
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	layout, rows, err := readInput(*input, cfg.Columns)
+	if err != nil {
+		return err
+	}
+	slugRows := make([]slug.Row, len(rows))
+	for i, row := range rows {
+		record := layout.Record(row)
+		slugRows[i] = slug.Row{Sku: record.Sku, OldSlug: record.OldSlug, NewSlug: record.NewSlug}
+	}
+	issues := slug.Check(slugRows)
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("failed creating file: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+	if err := writeSlugIssues(w, issues); err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(issues) > 0 {
+		return fmt.Errorf("%d slug issues in %d rows", len(issues), len(rows))
+	}
+	return nil
+}
+
+func writeSlugIssues(w io.Writer, issues []slug.Issue) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"Kind", "Sku", "Old Slug", "New Slug", "Expected", "Detail"})
+	for _, issue := range issues {
+		writer.Write([]string{string(issue.Kind), issue.Sku, issue.OldSlug, issue.NewSlug, issue.Expected, issue.Detail})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed writing issues: %w", err)
+	}
+	return nil
+}
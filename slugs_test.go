@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunSlugs(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.csv")
+	output := filepath.Join(dir, "issues.csv")
+	require.NoError(t, os.WriteFile(input, []byte(inputHeader+
+		"1,tamanho:g,tamanho-g,,,,,,,,,,,\n"+
+		"2,Água Oxigenada,agua-oxigenada-10,,,,,,,,,,,\n"+
+		"3,tamanho:G,,,,,,,,,,,,\n"+
+		"4,dipirona-500mg,,,,,,,,,,,,\n",
+	), 0o600))
+
+	err := runSlugs(context.Background(), []string{"-input", input, "-output", output})
+	require.EqualError(t, err, "3 slug issues in 4 rows")
+
+	records := readOutput(t, output)
+	require.Equal(t, [][]string{
+		{"Kind", "Sku", "Old Slug", "New Slug", "Expected", "Detail"},
+		{"MISMATCH", "2", "Água Oxigenada", "agua-oxigenada-10", "agua-oxigenada", "New Slug differs from the one generated from Old Slug"},
+		{"MISSING", "3", "tamanho:G", "", "tamanho-g", "Old Slug is not a valid slug and has no New Slug"},
+		{"COLLISION", "1,3", "", "tamanho-g", "tamanho-g", "new slug shared by 2 skus"},
+	}, records)
+}
+
+func TestRunSlugsInvalidConfig(t *testing.T) {
+	configPath := writeInvalidConfig(t, t.TempDir())
+	err := runSlugs(context.Background(), []string{"-config", configPath})
+	require.EqualError(t, err, "config: timeout can not be negative, got -1s")
+}
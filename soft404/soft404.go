@@ -0,0 +1,208 @@
+package soft404
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// DefaultSimilarity is how close to the known-missing page a body must be,
+// from 0 to 1, to be taken for it.
+const DefaultSimilarity = 0.9
+
+// shingleSize is the number of words compared at once by the similarity.
+const shingleSize = 3
+
+var (
+	titleTag     = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	invisibleTag = regexp.MustCompile(`(?is)<(script|style|noscript)[^>]*>.*?</(script|style|noscript)>`)
+	anyTag       = regexp.MustCompile(`(?s)<[^>]*>`)
+)
+
+// Config tells which pages answering 200 are actually missing.
+type Config struct {
+	// TitlePatterns and TextPatterns are regular expressions matched against
+	// the page title and its visible text.
+	TitlePatterns []string `yaml:"titlePatterns"`
+	TextPatterns  []string `yaml:"textPatterns"`
+	// RedirectToHome flags URLs redirected to the home page of their host.
+	RedirectToHome bool `yaml:"redirectToHome"`
+	// SearchPaths flags URLs redirected to one of these paths, like "/busca".
+	SearchPaths []string `yaml:"searchPaths"`
+	// MissingURL is a URL known not to exist. Pages whose text is at least
+	// Similarity alike to what it answers are taken as missing.
+	MissingURL string  `yaml:"missingURL"`
+	Similarity float64 `yaml:"similarity"`
+}
+
+// Default returns a configuration detecting nothing.
+func Default() Config {
+	return Config{Similarity: DefaultSimilarity}
+}
+
+// Page is the end of a redirect chain answering 200.
+type Page struct {
+	URL      string
+	FinalURL string
+	Body     string
+}
+
+// Detector finds soft 404 pages.
+type Detector struct {
+	config      Config
+	titles      []*regexp.Regexp
+	texts       []*regexp.Regexp
+	fingerprint map[string]bool
+}
+
+// New compiles the patterns of config.
+func New(config Config) (*Detector, error) {
+	d := &Detector{config: config}
+	for _, pattern := range config.TitlePatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("soft404: invalid title pattern %q: %w", pattern, err)
+		}
+		d.titles = append(d.titles, re)
+	}
+	for _, pattern := range config.TextPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("soft404: invalid text pattern %q: %w", pattern, err)
+		}
+		d.texts = append(d.texts, re)
+	}
+	if config.Similarity <= 0 || config.Similarity > 1 {
+		return nil, fmt.Errorf("soft404: similarity must be above 0 and at most 1, got %v", config.Similarity)
+	}
+	return d, nil
+}
+
+// NeedsBody reports whether detection looks at the page bodies.
+func (d *Detector) NeedsBody() bool {
+	return len(d.titles) > 0 || len(d.texts) > 0 || d.config.MissingURL != ""
+}
+
+// SetFingerprint keeps the body answered by the known-missing URL. A page
+// without text leaves the comparison off.
+func (d *Detector) SetFingerprint(body string) {
+	if fingerprint := shingles(Text(body)); len(fingerprint) > 0 {
+		d.fingerprint = fingerprint
+	}
+}
+
+// Detect returns why page is a soft 404, or "" when it looks like a real page.
+func (d *Detector) Detect(page Page) string {
+	if reason := d.redirected(page); reason != "" {
+		return reason
+	}
+
+	if len(d.titles) > 0 {
+		title := Title(page.Body)
+		for _, re := range d.titles {
+			if re.MatchString(title) {
+				return fmt.Sprintf("title matches %q", re.String())
+			}
+		}
+	}
+
+	var text string
+	if len(d.texts) > 0 || d.fingerprint != nil {
+		text = Text(page.Body)
+	}
+	for _, re := range d.texts {
+		if re.MatchString(text) {
+			return fmt.Sprintf("text matches %q", re.String())
+		}
+	}
+
+	if d.fingerprint != nil {
+		if similarity := jaccard(d.fingerprint, shingles(text)); similarity >= d.config.Similarity {
+			return fmt.Sprintf("%.0f%% similar to %s", similarity*100, d.config.MissingURL)
+		}
+	}
+	return ""
+}
+
+func (d *Detector) redirected(page Page) string {
+	if page.FinalURL == "" || page.FinalURL == page.URL {
+		return ""
+	}
+	original, err := url.Parse(page.URL)
+	if err != nil {
+		return ""
+	}
+	final, err := url.Parse(page.FinalURL)
+	if err != nil {
+		return ""
+	}
+
+	if d.config.RedirectToHome && isHome(final.Path) && !isHome(original.Path) {
+		return "redirected to the home page"
+	}
+	for _, path := range d.config.SearchPaths {
+		path = strings.TrimSuffix(path, "/")
+		if path != "" && (final.Path == path || strings.HasPrefix(final.Path, path+"/")) {
+			return "redirected to the search page " + path
+		}
+	}
+	return ""
+}
+
+func isHome(path string) bool {
+	return path == "" || path == "/"
+}
+
+// Title returns the text of the title tag of an HTML page.
+func Title(body string) string {
+	match := titleTag.FindStringSubmatch(body)
+	if match == nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(html.UnescapeString(match[1])), " ")
+}
+
+// Text returns the visible text of an HTML page, with spaces collapsed.
+func Text(body string) string {
+	body = invisibleTag.ReplaceAllString(body, " ")
+	body = anyTag.ReplaceAllString(body, " ")
+	return strings.Join(strings.Fields(html.UnescapeString(body)), " ")
+}
+
+// shingles returns the set of lowercase word sequences of text.
+func shingles(text string) map[string]bool {
+	words := strings.Fields(strings.ToLower(text))
+	set := map[string]bool{}
+	if len(words) < shingleSize {
+		if len(words) > 0 {
+			set[strings.Join(words, " ")] = true
+		}
+		return set
+	}
+	for i := 0; i+shingleSize <= len(words); i++ {
+		set[strings.Join(words[i:i+shingleSize], " ")] = true
+	}
+	return set
+}
+
+// Similarity returns how alike two texts are, from 0 for nothing in common
+// to 1 for the same words in the same order.
+func Similarity(a, b string) float64 {
+	return jaccard(shingles(a), shingles(b))
+}
+
+// jaccard returns the share of shingles found in both sets.
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	common := 0
+	for s := range a {
+		if b[s] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
@@ -0,0 +1,136 @@
+package soft404_test
+
+import (
+	"testing"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/soft404"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	missingPage = `<html><head><title>Página não encontrada | CliqueFarma</title>
+<style>body { color: red }</style></head>
+<body><h1>Ops! Não encontramos o produto que você procura.</h1>
+<p>Confira outras ofertas de medicamentos, dermocosméticos e higiene pessoal.</p></body></html>`
+	productPage = `<html><head><title>Dipirona 500mg 10 comprimidos | CliqueFarma</title></head>
+<body><h1>Dipirona 500mg 10 comprimidos</h1><p>Analgésico e antitérmico. Por R$ 5,99 à vista.</p></body></html>`
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		desc string
+
+		config      func(cfg *soft404.Config)
+		fingerprint string
+		page        soft404.Page
+		reason      string
+	}{
+		{
+			desc:   "default detecting nothing",
+			config: func(cfg *soft404.Config) {},
+			page:   soft404.Page{URL: "https://www.cliquefarma.com.br/a", FinalURL: "https://www.cliquefarma.com.br/", Body: missingPage},
+		},
+		{
+			desc:   "title pattern",
+			config: func(cfg *soft404.Config) { cfg.TitlePatterns = []string{`(?i)não encontrad`} },
+			page:   soft404.Page{Body: missingPage},
+			reason: `title matches "(?i)não encontrad"`,
+		},
+		{
+			desc:   "title pattern on a product",
+			config: func(cfg *soft404.Config) { cfg.TitlePatterns = []string{`(?i)não encontrad`} },
+			page:   soft404.Page{Body: productPage},
+		},
+		{
+			desc:   "text pattern ignoring styles",
+			config: func(cfg *soft404.Config) { cfg.TextPatterns = []string{`color: red`, `Não encontramos o produto`} },
+			page:   soft404.Page{Body: missingPage},
+			reason: `text matches "Não encontramos o produto"`,
+		},
+		{
+			desc:   "redirect to home",
+			config: func(cfg *soft404.Config) { cfg.RedirectToHome = true },
+			page:   soft404.Page{URL: "https://www.cliquefarma.com.br/dipirona", FinalURL: "https://www.cliquefarma.com.br/", Body: productPage},
+			reason: "redirected to the home page",
+		},
+		{
+			desc:   "home itself",
+			config: func(cfg *soft404.Config) { cfg.RedirectToHome = true },
+			page:   soft404.Page{URL: "https://www.cliquefarma.com.br", FinalURL: "https://www.cliquefarma.com.br/", Body: productPage},
+		},
+		{
+			desc:   "redirect to search",
+			config: func(cfg *soft404.Config) { cfg.SearchPaths = []string{"/busca/"} },
+			page:   soft404.Page{URL: "https://www.cliquefarma.com.br/dipirona", FinalURL: "https://www.cliquefarma.com.br/busca?q=dipirona", Body: productPage},
+			reason: "redirected to the search page /busca",
+		},
+		{
+			desc: "similar to the known missing page",
+			config: func(cfg *soft404.Config) {
+				cfg.MissingURL = "https://www.cliquefarma.com.br/nao-existe"
+			},
+			fingerprint: missingPage,
+			page:        soft404.Page{Body: missingPage},
+			reason:      "100% similar to https://www.cliquefarma.com.br/nao-existe",
+		},
+		{
+			desc: "unlike the known missing page",
+			config: func(cfg *soft404.Config) {
+				cfg.MissingURL = "https://www.cliquefarma.com.br/nao-existe"
+			},
+			fingerprint: missingPage,
+			page:        soft404.Page{Body: productPage},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			cfg := soft404.Default()
+			tC.config(&cfg)
+			detector, err := soft404.New(cfg)
+			require.NoError(t, err)
+			if tC.fingerprint != "" {
+				detector.SetFingerprint(tC.fingerprint)
+			}
+			require.Equal(t, tC.reason, detector.Detect(tC.page))
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	testCases := []struct {
+		desc string
+
+		config           func(cfg *soft404.Config)
+		errAssertionFunc require.ErrorAssertionFunc
+	}{
+		{
+			desc:             "default",
+			config:           func(cfg *soft404.Config) {},
+			errAssertionFunc: require.NoError,
+		},
+		{
+			desc:             "invalid title pattern",
+			config:           func(cfg *soft404.Config) { cfg.TitlePatterns = []string{"("} },
+			errAssertionFunc: require.Error,
+		},
+		{
+			desc:             "similarity out of range",
+			config:           func(cfg *soft404.Config) { cfg.Similarity = 1.5 },
+			errAssertionFunc: require.Error,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			cfg := soft404.Default()
+			tC.config(&cfg)
+			_, err := soft404.New(cfg)
+			tC.errAssertionFunc(t, err)
+		})
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	require.Equal(t, 1.0, soft404.Similarity("a b c d", "A B C D"))
+	require.Equal(t, 0.0, soft404.Similarity("a b c d", "e f g h"))
+	require.InDelta(t, 1.0/3, soft404.Similarity("a b c d", "a b c e"), 0.001)
+}
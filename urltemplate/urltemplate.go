@@ -0,0 +1,180 @@
+package urltemplate
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/columns"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/slug"
+)
+
+const subcategoriaPrefix = "subcategoria"
+
+// Template builds the URL of a product from its slug and category columns,
+// like "{base}/{departamento}/{slug}". Placeholders are {base}, {sku},
+// {slug}, {departamento}, {categoria} and {subcategoria1}, {subcategoria2}...
+// Category values are turned into slugs, and placeholders left empty drop
+// their path segment.
+type Template struct {
+	raw   string
+	parts []part
+}
+
+// part is a literal piece of the template, or a placeholder when name is set.
+type part struct {
+	literal string
+	name    string
+}
+
+// Parse reads a template. It must hold {slug} and start with {base} or an
+// absolute URL.
+func Parse(s string) (*Template, error) {
+	t := &Template{raw: s}
+	rest := s
+	for rest != "" {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			t.parts = append(t.parts, part{literal: rest})
+			break
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("urltemplate: unclosed placeholder in %q", s)
+		}
+		if start > 0 {
+			t.parts = append(t.parts, part{literal: rest[:start]})
+		}
+		name := rest[start+1 : start+end]
+		if !known(name) {
+			return nil, fmt.Errorf("urltemplate: unknown placeholder {%s} in %q", name, s)
+		}
+		t.parts = append(t.parts, part{name: name})
+		rest = rest[start+end+1:]
+	}
+
+	if !t.Uses("slug") {
+		return nil, fmt.Errorf("urltemplate: %q has no {slug}", s)
+	}
+	if !t.Uses("base") && !strings.HasPrefix(s, "http://") && !strings.HasPrefix(s, "https://") {
+		return nil, fmt.Errorf("urltemplate: %q must start with {base} or an absolute url", s)
+	}
+	return t, nil
+}
+
+// String returns the template as given to Parse.
+func (t *Template) String() string {
+	return t.raw
+}
+
+// Uses reports whether the template holds the placeholder name.
+func (t *Template) Uses(name string) bool {
+	for _, p := range t.parts {
+		if p.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Build returns the URL of record with the given slug, base being the
+// scheme and host of {base}. It returns an empty string when slug is empty.
+func (t *Template) Build(base string, record columns.Record, productSlug string) string {
+	if productSlug == "" {
+		return ""
+	}
+	var b strings.Builder
+	for _, p := range t.parts {
+		if p.name == "" {
+			b.WriteString(p.literal)
+			continue
+		}
+		b.WriteString(value(p.name, base, record, productSlug))
+	}
+	return collapseSlashes(b.String())
+}
+
+// Fill derives the URLs missing from the pairs of record. Pairs with only
+// one of De and Para get the other built from Old Slug or New Slug. When no
+// pair has any URL, the first pair gets both. Derived URLs are flagged in
+// the pair.
+func (t *Template) Fill(base string, record columns.Record) columns.Record {
+	pairs := make([]columns.URLPair, len(record.Pairs))
+	copy(pairs, record.Pairs)
+
+	empty := true
+	for _, pair := range pairs {
+		if pair.From != "" || pair.To != "" {
+			empty = false
+			break
+		}
+	}
+	for i, pair := range pairs {
+		if pair.From == "" && pair.To == "" && (!empty || i > 0) {
+			continue
+		}
+		if pair.From == "" {
+			pairs[i].From = t.Build(base, record, record.OldSlug)
+			pairs[i].FromDerived = pairs[i].From != ""
+		}
+		if pair.To == "" {
+			pairs[i].To = t.Build(base, record, record.NewSlug)
+			pairs[i].ToDerived = pairs[i].To != ""
+		}
+	}
+	record.Pairs = pairs
+	return record
+}
+
+func known(name string) bool {
+	switch name {
+	case "base", "sku", "slug", "departamento", "categoria":
+		return true
+	}
+	n, ok := subcategoria(name)
+	return ok && n > 0
+}
+
+func subcategoria(name string) (int, bool) {
+	if !strings.HasPrefix(name, subcategoriaPrefix) {
+		return 0, false
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(name, subcategoriaPrefix))
+	return n, err == nil
+}
+
+func value(name string, base string, record columns.Record, productSlug string) string {
+	switch name {
+	case "base":
+		return strings.TrimSuffix(base, "/")
+	case "sku":
+		return url.PathEscape(record.Sku)
+	case "slug":
+		return url.PathEscape(productSlug)
+	case "departamento":
+		return slug.Generate(record.Departamento)
+	case "categoria":
+		return slug.Generate(record.Categoria)
+	}
+	if n, ok := subcategoria(name); ok && n <= len(record.Subcategorias) {
+		return slug.Generate(record.Subcategorias[n-1])
+	}
+	return ""
+}
+
+// collapseSlashes turns the "//" left by empty placeholders into "/",
+// keeping the one after the scheme.
+func collapseSlashes(s string) string {
+	scheme, rest, ok := strings.Cut(s, "://")
+	if !ok {
+		scheme, rest = "", s
+	}
+	for strings.Contains(rest, "//") {
+		rest = strings.ReplaceAll(rest, "//", "/")
+	}
+	if !ok {
+		return rest
+	}
+	return scheme + "://" + rest
+}
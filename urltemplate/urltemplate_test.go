@@ -0,0 +1,112 @@
+package urltemplate_test
+
+import (
+	"testing"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/columns"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/urltemplate"
+	"github.com/stretchr/testify/require"
+)
+
+const base = "https://www.cliquefarma.com.br"
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		desc string
+
+		template         string
+		errAssertionFunc require.ErrorAssertionFunc
+	}{
+		{desc: "base and slug", template: "{base}/{departamento}/{slug}", errAssertionFunc: require.NoError},
+		{desc: "absolute url", template: base + "/{subcategoria2}/{sku}-{slug}", errAssertionFunc: require.NoError},
+		{desc: "no slug", template: "{base}/{departamento}", errAssertionFunc: require.Error},
+		{desc: "relative", template: "/{departamento}/{slug}", errAssertionFunc: require.Error},
+		{desc: "unknown placeholder", template: "{base}/{marca}/{slug}", errAssertionFunc: require.Error},
+		{desc: "subcategoria without number", template: "{base}/{subcategoria}/{slug}", errAssertionFunc: require.Error},
+		{desc: "unclosed placeholder", template: "{base}/{slug", errAssertionFunc: require.Error},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			_, err := urltemplate.Parse(tC.template)
+			tC.errAssertionFunc(t, err)
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	record := columns.Record{
+		Sku:           "123",
+		Departamento:  "Mamãe e Bebê",
+		Categoria:     "Fraldas",
+		Subcategorias: []string{"Tamanho G"},
+	}
+
+	testCases := []struct {
+		desc string
+
+		template string
+		slug     string
+		expected string
+	}{
+		{desc: "departamento", template: "{base}/{departamento}/{slug}", slug: "fralda-g", expected: base + "/mamae-e-bebe/fralda-g"},
+		{desc: "every column", template: "{base}/{departamento}/{categoria}/{subcategoria1}/{sku}/{slug}", slug: "fralda-g", expected: base + "/mamae-e-bebe/fraldas/tamanho-g/123/fralda-g"},
+		{desc: "empty column dropped", template: "{base}/{subcategoria2}/{slug}", slug: "fralda-g", expected: base + "/fralda-g"},
+		{desc: "old slug kept as it is", template: "{base}/{slug}", slug: "fralda:g", expected: base + "/fralda:g"},
+		{desc: "no slug", template: "{base}/{slug}", slug: "", expected: ""},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			template, err := urltemplate.Parse(tC.template)
+			require.NoError(t, err)
+			require.Equal(t, tC.expected, template.Build(base+"/", record, tC.slug))
+		})
+	}
+}
+
+func TestFill(t *testing.T) {
+	template, err := urltemplate.Parse("{base}/{departamento}/{slug}")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		desc string
+
+		record   columns.Record
+		expected []columns.URLPair
+	}{
+		{
+			desc: "no url",
+			record: columns.Record{OldSlug: "fralda:g", NewSlug: "fralda-g", Departamento: "Bebê",
+				Pairs: []columns.URLPair{{N: 1}, {N: 2}}},
+			expected: []columns.URLPair{
+				{N: 1, From: base + "/bebe/fralda:g", To: base + "/bebe/fralda-g", FromDerived: true, ToDerived: true},
+				{N: 2},
+			},
+		},
+		{
+			desc: "missing para",
+			record: columns.Record{OldSlug: "fralda:g", NewSlug: "fralda-g", Departamento: "Bebê",
+				Pairs: []columns.URLPair{{N: 1, From: base + "/a"}, {N: 2}}},
+			expected: []columns.URLPair{
+				{N: 1, From: base + "/a", To: base + "/bebe/fralda-g", ToDerived: true},
+				{N: 2},
+			},
+		},
+		{
+			desc: "every url given",
+			record: columns.Record{OldSlug: "fralda:g", NewSlug: "fralda-g",
+				Pairs: []columns.URLPair{{N: 1, From: base + "/a", To: base + "/b"}}},
+			expected: []columns.URLPair{{N: 1, From: base + "/a", To: base + "/b"}},
+		},
+		{
+			desc: "no new slug",
+			record: columns.Record{OldSlug: "fralda:g",
+				Pairs: []columns.URLPair{{N: 1}}},
+			expected: []columns.URLPair{{N: 1, From: base + "/fralda:g", FromDerived: true}},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			require.Equal(t, tC.expected, template.Fill(base, tC.record).Pairs)
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/columns"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/config"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/graph"
+)
+
+// runValidate checks the De/Para pairs of every row as a whole redirect map,
+// without requesting any URL, and optionally writes the input with the
+// chains flattened.
+func runValidate(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	input := fs.String("input", config.DefaultInput, "CSV file with the products to validate")
+	output := fs.String("output", "", "CSV file to write the issues to, standard output when empty")
+	flattened := fs.String("flattened", "", "CSV file to write the input to, with every Para redirected again replaced by the last URL of its chain")
+	configPath := fs.String("config", "", "YAML config file with the input column aliases and the url template")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg := config.Default()
+	if *configPath != "" {
+		if err := config.LoadFile(*configPath, &cfg); err != nil {
+			return err
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	layout, rows, err := readInput(*input, cfg.Columns)
+	if err != nil {
+		return err
+	}
+	edges, err := recordEdges(cfg, layout, rows)
+	if err != nil {
+		return err
+	}
+	g := graph.New(edges)
+	issues := g.Validate()
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("failed creating file: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+	if err := writeIssues(w, issues); err != nil {
+		return err
+	}
+
+	if *flattened != "" {
+		changed, err := writeFlattened(*flattened, layout, rows, g)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "flattened %d Para URLs, written to %s\n", changed, *flattened)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(issues) > 0 {
+		return fmt.Errorf("%s in %d pairs", countIssues(issues), len(edges))
+	}
+	return nil
+}
+
+// recordEdges returns the De/Para pairs of rows, missing URLs built with
+// the URL template of cfg, when set.
+func recordEdges(cfg config.Config, layout *columns.Layout, rows [][]string) ([]graph.Edge, error) {
+	urls, err := cfg.URLs()
+	if err != nil {
+		return nil, err
+	}
+	var edges []graph.Edge
+	for _, row := range rows {
+		record := layout.Record(row)
+		if urls != nil {
+			record = urls.Fill(cfg.BaseHost, record)
+		}
+		for _, pair := range record.Pairs {
+			if pair.From == "" || pair.To == "" {
+				continue
+			}
+			edges = append(edges, graph.Edge{Sku: record.Sku, From: pair.From, To: pair.To})
+		}
+	}
+	return edges, nil
+}
+
+// readInput reads the header layout and the rows of an input file.
+func readInput(path string, aliases columns.Aliases) (*columns.Layout, [][]string, error) {
+	return readLayout(path, aliases, columns.Parse)
+}
+
+// readLayout reads the rows of a CSV file and its header layout, resolved
+// by parse.
+func readLayout(path string, aliases columns.Aliases, parse func([]string, columns.Aliases) (*columns.Layout, error)) (*columns.Layout, [][]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed opening input file: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed reading input header: %w", err)
+	}
+	layout, err := parse(header, aliases)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid input file %s: %w", path, err)
+	}
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed reading %s: %w", path, err)
+	}
+	return layout, rows, nil
+}
+
+// exportHeader is the header of the catalog team export, the one written by
+// writeInputRows.
+var exportHeader = []string{"Sku", "Old Slug", "New Slug", "Departamento", "Categoria", "Subcategoria1", "Subcategoria2", "Subcategoria3", "Url1De", "Url2De", "Url3De", "Url1Para", "Url2Para", "Url3Para"}
+
+// exportPairs and exportSubcategorias are the numbered columns of exportHeader.
+const (
+	exportPairs         = 3
+	exportSubcategorias = 3
+)
+
+// writeInputRows writes records to path in the layout of the catalog team
+// export, ready to be analyzed. Pairs and subcategories beyond the ones of
+// the layout are left out.
+func writeInputRows(path string, records []columns.Record) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed creating file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write(exportHeader)
+	for _, record := range records {
+		row := []string{record.Sku, record.OldSlug, record.NewSlug, record.Departamento, record.Categoria}
+		for i := 0; i < exportSubcategorias; i++ {
+			row = append(row, cellAt(record.Subcategorias, i))
+		}
+		froms, tos := make([]string, exportPairs), make([]string, exportPairs)
+		for i, pair := range record.Pairs {
+			if i < exportPairs {
+				froms[i], tos[i] = pair.From, pair.To
+			}
+		}
+		writer.Write(append(append(row, froms...), tos...))
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed writing %s: %w", path, err)
+	}
+	return file.Sync()
+}
+
+func cellAt(list []string, i int) string {
+	if i < len(list) {
+		return list[i]
+	}
+	return ""
+}
+
+func writeIssues(w io.Writer, issues []graph.Issue) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"Kind", "Sku", "De", "Para", "Flattened", "Detail"})
+	for _, issue := range issues {
+		writer.Write([]string{string(issue.Kind), issue.Sku, issue.From, issue.To, issue.Flattened, issue.Detail})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed writing issues: %w", err)
+	}
+	return nil
+}
+
+// writeFlattened writes rows to path, each Para URL replaced by the last URL
+// of its chain. Chains ending in a loop or a conflict are left as they are.
+// It returns how many Para URLs changed.
+func writeFlattened(path string, layout *columns.Layout, rows [][]string, g *graph.Graph) (int, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed creating file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write(layout.Header)
+	changed := 0
+	for _, row := range rows {
+		flat := append([]string(nil), row...)
+		for _, pair := range layout.Pairs {
+			if pair.To >= len(flat) || flat[pair.To] == "" {
+				continue
+			}
+			if final, ok := g.Resolve(flat[pair.To]); ok && final != flat[pair.To] {
+				flat[pair.To] = final
+				changed++
+			}
+		}
+		writer.Write(flat)
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return 0, fmt.Errorf("failed writing %s: %w", path, err)
+	}
+	return changed, file.Sync()
+}
+
+// countIssues formats the number of issues of each kind, like
+// "1 CHAIN, 2 LOOP".
+func countIssues(issues []graph.Issue) string {
+	kinds := make([]string, len(issues))
+	for i, issue := range issues {
+		kinds[i] = string(issue.Kind)
+	}
+	return countKinds(kinds)
+}
+
+// countKinds formats how many times each kind is in kinds, sorted by kind.
+func countKinds(kinds []string) string {
+	counts := map[string]int{}
+	for _, kind := range kinds {
+		counts[kind]++
+	}
+	sorted := make([]string, 0, len(counts))
+	for kind := range counts {
+		sorted = append(sorted, kind)
+	}
+	sort.Strings(sorted)
+
+	parts := make([]string, len(sorted))
+	for i, kind := range sorted {
+		parts[i] = fmt.Sprintf("%d %s", counts[kind], kind)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunValidate(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.csv")
+	output := filepath.Join(dir, "issues.csv")
+	flattened := filepath.Join(dir, "flattened.csv")
+	require.NoError(t, os.WriteFile(input, []byte(inputHeader+
+		"1,a,b,,,,,,https://www.cliquefarma.com.br/a,,,https://www.cliquefarma.com.br/b,,\n"+
+		"2,b,c,,,,,,https://www.cliquefarma.com.br/b,,,https://www.cliquefarma.com.br/c,,\n"+
+		"3,x,y,,,,,,https://www.cliquefarma.com.br/x,,,https://www.cliquefarma.com.br/y,,\n"+
+		"4,y,x,,,,,,https://www.cliquefarma.com.br/y,,,https://www.cliquefarma.com.br/x,,\n",
+	), 0o600))
+
+	err := runValidate(context.Background(), []string{"-input", input, "-output", output, "-flattened", flattened})
+	require.EqualError(t, err, "1 CHAIN, 1 LOOP, 1 PARA_IS_DE in 4 pairs")
+
+	records := readOutput(t, output)
+	require.Equal(t, []string{"Kind", "Sku", "De", "Para", "Flattened", "Detail"}, records[0])
+	require.Len(t, records, 4)
+	require.Equal(t, "LOOP", records[1][0])
+	require.Equal(t, []string{"CHAIN", "1", "https://www.cliquefarma.com.br/a", "https://www.cliquefarma.com.br/b", "https://www.cliquefarma.com.br/c", "https://www.cliquefarma.com.br/a -> https://www.cliquefarma.com.br/b -> https://www.cliquefarma.com.br/c"}, records[2])
+
+	flat := readOutput(t, flattened)
+	require.Len(t, flat, 5)
+	require.Equal(t, "https://www.cliquefarma.com.br/c", flat[1][11], "chain flattened")
+	require.Equal(t, "https://www.cliquefarma.com.br/y", flat[3][11], "loop left as it is")
+
+	// The flattened map has no chain left.
+	err = runValidate(context.Background(), []string{"-input", flattened, "-output", output})
+	require.EqualError(t, err, "1 LOOP in 4 pairs")
+}
+
+func TestRunValidateEncodedURLs(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.csv")
+	output := filepath.Join(dir, "issues.csv")
+	require.NoError(t, os.WriteFile(input, []byte(inputHeader+
+		"1,camiseta-tamanho:g,camiseta-tamanho-g,,,,,,https://www.cliquefarma.com.br/camiseta-tamanho%3Ag,,,https://www.cliquefarma.com.br/camiseta-tamanho-g,,\n"+
+		"2,camiseta-tamanho:g,camiseta-g,,,,,,https://www.cliquefarma.com.br/camiseta-tamanho:g,,,https://www.cliquefarma.com.br/camiseta-g,,\n",
+	), 0o600))
+
+	err := runValidate(context.Background(), []string{"-input", input, "-output", output})
+	require.EqualError(t, err, "1 CONFLICT in 2 pairs", "both spellings are the same De URL")
+}
+
+// writeInvalidConfig writes a config file Config.Validate rejects.
+func writeInvalidConfig(t *testing.T, dir string) string {
+	t.Helper()
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("timeout: -1s\n"), 0o600))
+	return path
+}
+
+func TestRunValidateInvalidConfig(t *testing.T) {
+	configPath := writeInvalidConfig(t, t.TempDir())
+	err := runValidate(context.Background(), []string{"-config", configPath})
+	require.EqualError(t, err, "config: timeout can not be negative, got -1s")
+}
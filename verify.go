@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/checkpoint"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/columns"
+	"github.com/castmetal/cliquefarma-analize-redirect-csv/result"
+)
+
+const (
+	verifyPass = "PASS"
+	verifyFail = "FAIL"
+)
+
+func (r *RowReader) verifyRedirectAndWriteResponse(pair columns.URLPair, record columns.Record) {
+	probe, err := FetchHttp(r.ctx, pair.From, r.method, r.httpMeta)
+	if probe.Body != nil {
+		probe.Body.Close()
+	}
+
+	outcome, reason := verifyRedirect(probe, err, pair.To)
+
+	r.writeResponse(checkpoint.Key{Sku: record.Sku, From: pair.From, To: pair.To}, r.newResult(pair, record, outcome, reason, probe.result(), nil))
+}
+
+// verifyColumns are the CSV columns written by the verify command.
+var verifyColumns = []result.Column{
+	{Name: "Sku", Value: func(r result.Result) string { return r.Sku }},
+	{Name: "De", Value: func(r result.Result) string { return r.From }},
+	{Name: "Para", Value: func(r result.Result) string { return r.To }},
+	{Name: "Result", Value: func(r result.Result) string { return r.Status }},
+	{Name: "Reason", Value: func(r result.Result) string { return r.Explanation }},
+	{Name: "De Status", Value: func(r result.Result) string { return r.FromProbe.Status }},
+	{Name: "Location", Value: func(r result.Result) string {
+		if len(r.FromProbe.Hops) == 0 {
+			return ""
+		}
+		return r.FromProbe.Hops[0].Location
+	}},
+	{Name: "De Chain", Value: func(r result.Result) string { return r.FromProbe.Chain() }},
+	{Name: "Derived", Value: derivedColumn},
+}
+
+// verifyRedirect checks probe, the result of fetching a De URL, answered a
+// permanent redirect straight to the Para URL, which must then answer 200.
+// It returns PASS or FAIL and the reasons of a failure.
+func verifyRedirect(probe Probe, err error, to string) (string, string) {
+	if len(probe.Hops) == 0 {
+		return verifyFail, fmt.Sprintf("request failed with %s: %v", probe.Failure, err)
+	}
+
+	first := probe.Hops[0]
+	if !isRedirect(first.StatusCode) {
+		return verifyFail, fmt.Sprintf("no redirect, answered %d", first.StatusCode)
+	}
+	if errors.Is(err, errRedirectLoop) {
+		return verifyFail, "redirect loop: " + probe.Chain()
+	}
+
+	var reasons []string
+	if first.StatusCode != http.StatusMovedPermanently && first.StatusCode != http.StatusPermanentRedirect {
+		reasons = append(reasons, fmt.Sprintf("%d instead of 301", first.StatusCode))
+	}
+
+	switch {
+	case first.Location == "":
+		reasons = append(reasons, "redirect without location")
+	case !sameURL(first.Location, to):
+		if sameURL(probe.FinalURL, to) {
+			reasons = append(reasons, fmt.Sprintf("chain of %d hops to Para: %s", probe.Redirects(), probe.Chain()))
+		} else {
+			reasons = append(reasons, fmt.Sprintf("wrong target %s", first.Location))
+		}
+	case probe.Failure != "":
+		reasons = append(reasons, fmt.Sprintf("Para request failed with %s", probe.Failure))
+	case probe.Redirects() > 1:
+		reasons = append(reasons, "Para redirects again: "+probe.Chain())
+	case probe.FinalStatusCode != http.StatusOK:
+		reasons = append(reasons, fmt.Sprintf("Para answers %d", probe.FinalStatusCode))
+	}
+
+	if len(reasons) > 0 {
+		return verifyFail, strings.Join(reasons, "; ")
+	}
+	return verifyPass, fmt.Sprintf("%d to Para", first.StatusCode)
+}
+
+// sameURL compares two absolute URLs ignoring the case of scheme and host.
+func sameURL(a string, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return a == b
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return a == b
+	}
+	return strings.EqualFold(ua.Scheme, ub.Scheme) &&
+		strings.EqualFold(ua.Host, ub.Host) &&
+		ua.EscapedPath() == ub.EscapedPath() &&
+		ua.RawQuery == ub.RawQuery
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newDeployedSite(t *testing.T) *httptest.Server {
+	t.Helper()
+	redirects := map[string]struct {
+		location string
+		status   int
+	}{
+		"/permanent":    {"/new", http.StatusMovedPermanently},
+		"/permanent308": {"/new", http.StatusPermanentRedirect},
+		"/temporary":    {"/new", http.StatusFound},
+		"/chain":        {"/permanent", http.StatusMovedPermanently},
+		"/wrong":        {"/other", http.StatusMovedPermanently},
+		"/broken":       {"/missing", http.StatusMovedPermanently},
+		"/loop":         {"/loop", http.StatusMovedPermanently},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if redirect, ok := redirects[r.URL.Path]; ok {
+			http.Redirect(w, r, redirect.location, redirect.status)
+			return
+		}
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("<html>page</html>"))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestVerifyRedirect(t *testing.T) {
+	site := newDeployedSite(t)
+
+	testCases := []struct {
+		desc string
+
+		from           string
+		to             string
+		expectedResult string
+		expectedReason string
+	}{
+		{desc: "301 straight to Para", from: "/permanent", to: "/new", expectedResult: verifyPass, expectedReason: "301 to Para"},
+		{desc: "308 straight to Para", from: "/permanent308", to: "/new", expectedResult: verifyPass, expectedReason: "308 to Para"},
+		{desc: "302 instead of 301", from: "/temporary", to: "/new", expectedResult: verifyFail, expectedReason: "302 instead of 301"},
+		{desc: "chain to Para", from: "/chain", to: "/new", expectedResult: verifyFail, expectedReason: "chain of 2 hops to Para"},
+		{desc: "wrong target", from: "/wrong", to: "/new", expectedResult: verifyFail, expectedReason: "wrong target"},
+		{desc: "Para not found", from: "/broken", to: "/missing", expectedResult: verifyFail, expectedReason: "Para answers 404"},
+		{desc: "loop", from: "/loop", to: "/new", expectedResult: verifyFail, expectedReason: "redirect loop"},
+		{desc: "no redirect", from: "/new", to: "/other", expectedResult: verifyFail, expectedReason: "no redirect, answered 200"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			probe, err := FetchHttp(context.Background(), site.URL+tC.from, "GET", nil)
+			result, reason := verifyRedirect(probe, err, site.URL+tC.to)
+			require.Equal(t, tC.expectedResult, result)
+			require.Contains(t, reason, tC.expectedReason)
+		})
+	}
+}
+
+func TestRunVerify(t *testing.T) {
+	site := newDeployedSite(t)
+	dir := t.TempDir()
+
+	input := inputHeader +
+		"1,,,,,,,,SITE/permanent,,,SITE/new,,\n" +
+		"2,,,,,,,,SITE/temporary,,,SITE/new,,\n"
+
+	cfg := newTestConfig(dir)
+	cfg.Output = filepath.Join(dir, "verify.csv")
+	require.NoError(t, os.WriteFile(cfg.Input, []byte(strings.ReplaceAll(input, "SITE", site.URL)), 0o600))
+
+	err := run(context.Background(), cfg, ModeVerify)
+	require.ErrorContains(t, err, "1 redirects failed verification")
+
+	records := readOutput(t, cfg.Output)
+	require.Len(t, records, 3)
+	results := map[string]string{}
+	for _, record := range records[1:] {
+		results[record[0]] = record[3]
+	}
+	require.Equal(t, map[string]string{"1": verifyPass, "2": verifyFail}, results)
+}